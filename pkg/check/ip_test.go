@@ -0,0 +1,30 @@
+package check_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/system18188/jupiter-plugin/pkg/check"
+)
+
+// testdata/ip.json is shared with the copies in server/xrestful/ip.go
+func TestIsIPAndCIDR(t *testing.T) {
+	b, err := ioutil.ReadFile("testdata/ip.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cases []struct {
+		In   string `json:"in"`
+		IP   bool   `json:"ip"`
+		CIDR bool   `json:"cidr"`
+	}
+	if err := json.Unmarshal(b, &cases); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cases {
+		if check.IsIP(c.In) != c.IP || check.IsCIDR(c.In) != c.CIDR {
+			t.Errorf("%q: IsIP %v IsCIDR %v", c.In, check.IsIP(c.In), check.IsCIDR(c.In))
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	xfilepath "github.com/system18188/jupiter-plugin/pkg/filepath"
+)
+
+// LocalStorage stores files under a directory
+type LocalStorage struct {
+	root string
+	// 目录权限, 默认0755
+	DirMode os.FileMode
+	// 文件权限, 默认0644
+	FileMode os.FileMode
+}
+
+// NewLocalStorage stores files under root, Save returns the file path
+func NewLocalStorage(root string) *LocalStorage {
+	return &LocalStorage{root: root, DirMode: 0755, FileMode: 0644}
+}
+
+func (s *LocalStorage) path(key string) (string, error) {
+	name := filepath.Join(s.root, filepath.FromSlash(key))
+	rel, err := filepath.Rel(s.root, name)
+	if err != nil || rel == "." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || rel == ".." {
+		return "", fmt.Errorf("upload: invalid key %q", key)
+	}
+	return name, nil
+}
+
+// Save implements Storage, the file is written to a temp file then renamed
+func (s *LocalStorage) Save(ctx context.Context, key string, r io.Reader, contentType string) (string, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Dir(name)
+	if err := xfilepath.MkDirAll(dir, s.DirMode); err != nil {
+		return "", err
+	}
+	tmp, err := ioutil.TempFile(dir, ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		xfilepath.CloseQuietly(tmp)
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(tmp.Name(), s.FileMode); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// Delete implements Storage
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
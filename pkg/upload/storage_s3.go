@@ -0,0 +1,206 @@
+package upload
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	xfilepath "github.com/system18188/jupiter-plugin/pkg/filepath"
+)
+
+// S3Config S3 compatible object storage options, e.g. AWS S3, MinIO or Ceph RGW
+type S3Config struct {
+	// 服务地址, 如 https://s3.us-east-1.amazonaws.com 或 http://127.0.0.1:9000
+	Endpoint  string `json:"endpoint" toml:"endpoint"`
+	Region    string `json:"region" toml:"region"`
+	Bucket    string `json:"bucket" toml:"bucket"`
+	AccessKey string `json:"accessKey" toml:"accessKey"`
+	SecretKey string `json:"secretKey" toml:"secretKey"`
+	// 使用 endpoint/bucket/key 形式的地址, 否则为 bucket.endpoint/key, MinIO 需要开启
+	PathStyle bool `json:"pathStyle" toml:"pathStyle"`
+	// 对象的访问控制, 如 public-read, 为空时使用bucket的默认值
+	ACL string `json:"acl" toml:"acl"`
+}
+
+// S3Storage stores files in a bucket of an S3 compatible service. S3 needs the
+// length of an object before it is sent, so files are spooled to a temp file first.
+type S3Storage struct {
+	config   S3Config
+	endpoint *url.URL
+	client   *http.Client
+}
+
+// NewS3Storage ...
+func NewS3Storage(config S3Config) (*S3Storage, error) {
+	u, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" || config.Bucket == "" {
+		return nil, fmt.Errorf("upload: invalid s3 endpoint %q or bucket %q", config.Endpoint, config.Bucket)
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	return &S3Storage{config: config, endpoint: u, client: http.DefaultClient}, nil
+}
+
+// WithClient ...
+func (s *S3Storage) WithClient(client *http.Client) *S3Storage {
+	s.client = client
+	return s
+}
+
+// objectURL returns the url of key, the key is escaped per path segment
+func (s *S3Storage) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	segments := strings.Split(strings.TrimPrefix(key, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = s3Escape(segment)
+	}
+	escaped := strings.Join(segments, "/")
+	if s.config.PathStyle {
+		u.RawPath = strings.TrimSuffix(u.Path, "/") + "/" + s3Escape(s.config.Bucket) + "/" + escaped
+	} else {
+		u.Host = s.config.Bucket + "." + u.Host
+		u.RawPath = strings.TrimSuffix(u.Path, "/") + "/" + escaped
+	}
+	u.Path, _ = url.PathUnescape(u.RawPath)
+	return &u
+}
+
+// Save implements Storage, it returns the object url
+func (s *S3Storage) Save(ctx context.Context, key string, r io.Reader, contentType string) (string, error) {
+	tmp, err := ioutil.TempFile("", "upload-s3-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer xfilepath.CloseQuietly(tmp)
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	u := s.objectURL(key)
+	req, err := http.NewRequest(http.MethodPut, u.String(), ioutil.NopCloser(tmp))
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType)
+	if s.config.ACL != "" {
+		req.Header.Set("X-Amz-Acl", s.config.ACL)
+	}
+	s.sign(req, hex.EncodeToString(h.Sum(nil)), time.Now())
+	if err := s.do(ctx, req); err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// Delete implements Storage
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	req, err := http.NewRequest(http.MethodDelete, s.objectURL(key).String(), nil)
+	if err != nil {
+		return err
+	}
+	s.sign(req, emptySHA256, time.Now())
+	return s.do(ctx, req)
+}
+
+func (s *S3Storage) do(ctx context.Context, req *http.Request) error {
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("upload: s3 %s %s: %s %s", req.Method, req.URL.Path, resp.Status, msg)
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	return nil
+}
+
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// sign adds the AWS Signature Version 4 headers to req
+func (s *S3Storage) sign(req *http.Request, payloadHash string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	// 签名 host 和所有 x-amz-* 头
+	signed := []string{"host"}
+	for name := range req.Header {
+		if name = strings.ToLower(name); strings.HasPrefix(name, "x-amz-") {
+			signed = append(signed, name)
+		}
+	}
+	sort.Strings(signed)
+	var headers strings.Builder
+	for _, name := range signed {
+		value := req.URL.Host
+		if name != "host" {
+			value = strings.TrimSpace(req.Header.Get(name))
+		}
+		headers.WriteString(name + ":" + value + "\n")
+	}
+	signedHeaders := strings.Join(signed, ";")
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		headers.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + s.config.Region + "/s3/aws4_request"
+	sum := sha256.Sum256([]byte(canonical))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretKey), date)
+	key = hmacSHA256(key, s.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.config.AccessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3Escape escapes a path segment as required by SigV4, only unreserved characters are kept
+func s3Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,336 @@
+// Package upload streams multipart/form-data uploads into a Storage. Unlike
+// http.Request.ParseMultipartForm nothing is buffered in memory or temp files:
+// every part is checked against the limits, its type is sniffed from the
+// content and checksums are computed while it is written to the storage.
+package upload
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// sniffLen is the number of bytes http.DetectContentType looks at
+const sniffLen = 512
+
+// Error is an upload rejected by the limits, Status is the HTTP status to answer
+type Error struct {
+	Status int
+	Reason string
+}
+
+// Error ...
+func (e *Error) Error() string {
+	return e.Reason
+}
+
+var (
+	// ErrNotMultipart ...
+	ErrNotMultipart = &Error{Status: http.StatusUnsupportedMediaType, Reason: "request is not multipart/form-data"}
+	// ErrFileTooLarge ...
+	ErrFileTooLarge = &Error{Status: http.StatusRequestEntityTooLarge, Reason: "file too large"}
+	// ErrTotalTooLarge ...
+	ErrTotalTooLarge = &Error{Status: http.StatusRequestEntityTooLarge, Reason: "upload too large"}
+	// ErrTooManyFiles ...
+	ErrTooManyFiles = &Error{Status: http.StatusRequestEntityTooLarge, Reason: "too many files"}
+	// ErrFieldTooLarge ...
+	ErrFieldTooLarge = &Error{Status: http.StatusRequestEntityTooLarge, Reason: "form field too large"}
+)
+
+// File is the metadata of a stored file
+type File struct {
+	// 表单字段名
+	Field string `json:"field"`
+	// 客户端提供的文件名
+	Filename string `json:"filename"`
+	// 根据内容识别的类型, 而不是客户端提供的Content-Type
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256"`
+	MD5         string `json:"md5"`
+	// 存储中的key
+	Key string `json:"key"`
+	// Storage.Save 返回的位置, 如本地路径或对象URL
+	Location string `json:"location"`
+}
+
+// Result is a parsed upload
+type Result struct {
+	Files []*File
+	// 非文件字段
+	Values url.Values
+}
+
+// File returns the first file of field
+func (r *Result) File(field string) *File {
+	for _, f := range r.Files {
+		if f.Field == field {
+			return f
+		}
+	}
+	return nil
+}
+
+// Storage saves uploaded files, see NewLocalStorage and NewS3Storage
+type Storage interface {
+	// Save writes r under key and returns where it was stored,
+	// nothing must be kept when reading r fails
+	Save(ctx context.Context, key string, r io.Reader, contentType string) (location string, err error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Config upload limits
+type Config struct {
+	// 单个文件上限, 默认10MB
+	MaxFileSize int64
+	// 所有文件合计上限, 默认32MB
+	MaxTotalSize int64
+	// 文件数上限, 默认10
+	MaxFiles int
+	// 非文件字段合计上限, 默认1MB, 0为不限制
+	MaxFieldsSize int64
+	// 允许的类型, 支持 image/* 形式, 为空时允许所有类型
+	AllowedTypes []string
+	// 文件存储
+	Storage Storage
+	// 生成存储key, 默认为 日期/随机串.扩展名
+	KeyFunc func(file *File) string
+}
+
+// DefaultConfig ...
+func DefaultConfig() *Config {
+	return &Config{
+		MaxFileSize:   10 << 20,
+		MaxTotalSize:  32 << 20,
+		MaxFiles:      10,
+		MaxFieldsSize: 1 << 20,
+		KeyFunc:       DefaultKey,
+	}
+}
+
+// WithStorage ...
+func (config *Config) WithStorage(storage Storage) *Config {
+	config.Storage = storage
+	return config
+}
+
+// WithAllowedTypes ...
+func (config *Config) WithAllowedTypes(types ...string) *Config {
+	config.AllowedTypes = types
+	return config
+}
+
+// WithMaxFileSize ...
+func (config *Config) WithMaxFileSize(size int64) *Config {
+	config.MaxFileSize = size
+	return config
+}
+
+// WithMaxFiles ...
+func (config *Config) WithMaxFiles(n int) *Config {
+	config.MaxFiles = n
+	return config
+}
+
+// DefaultKey returns 2006/01/02/<random hex><ext>
+func DefaultKey(file *File) string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	ext := strings.ToLower(path.Ext(file.Filename))
+	if exts, _ := mime.ExtensionsByType(file.ContentType); len(exts) > 0 && !contains(exts, ext) {
+		ext = exts[0]
+	}
+	return time.Now().Format("2006/01/02/") + hex.EncodeToString(b) + ext
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse streams the files of r into the storage. When a limit is exceeded or a
+// file fails to save, the files already stored are deleted and an error is returned,
+// *Error for the limits.
+func (config *Config) Parse(r *http.Request) (*Result, error) {
+	if config.Storage == nil {
+		return nil, errors.New("upload: storage is required")
+	}
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, ErrNotMultipart
+	}
+	ctx := r.Context()
+	result := &Result{Values: make(url.Values)}
+	total, fieldsSize := int64(0), int64(0)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return result, nil
+		}
+		if err != nil {
+			config.cleanup(ctx, result)
+			return nil, err
+		}
+		if part.FileName() == "" {
+			remaining := int64(-1)
+			if config.MaxFieldsSize > 0 {
+				remaining = config.MaxFieldsSize - fieldsSize
+			}
+			value, err := readField(part, remaining)
+			part.Close()
+			if err != nil {
+				config.cleanup(ctx, result)
+				return nil, err
+			}
+			fieldsSize += int64(len(value))
+			result.Values.Add(part.FormName(), value)
+			continue
+		}
+		if config.MaxFiles > 0 && len(result.Files) >= config.MaxFiles {
+			part.Close()
+			config.cleanup(ctx, result)
+			return nil, ErrTooManyFiles
+		}
+		file, err := config.save(ctx, part, total)
+		part.Close()
+		if err != nil {
+			config.cleanup(ctx, result)
+			return nil, err
+		}
+		total += file.Size
+		result.Files = append(result.Files, file)
+	}
+}
+
+// readField reads a field of at most remaining bytes, a negative remaining means no limit
+func readField(r io.Reader, remaining int64) (string, error) {
+	if remaining < 0 {
+		b, err := ioutil.ReadAll(r)
+		return string(b), err
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(r, remaining+1))
+	if err != nil {
+		return "", err
+	}
+	if int64(len(b)) > remaining {
+		return "", ErrFieldTooLarge
+	}
+	return string(b), nil
+}
+
+type namedPart interface {
+	io.Reader
+	FileName() string
+	FormName() string
+}
+
+func (config *Config) save(ctx context.Context, part namedPart, total int64) (*File, error) {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(part, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	head = head[:n]
+	file := &File{
+		Field:       part.FormName(),
+		Filename:    path.Base(strings.ReplaceAll(part.FileName(), `\`, "/")),
+		ContentType: http.DetectContentType(head),
+	}
+	if !config.allowed(file.ContentType) {
+		return nil, &Error{Status: http.StatusUnsupportedMediaType, Reason: fmt.Sprintf("file type %s not allowed", file.ContentType)}
+	}
+	keyFunc := config.KeyFunc
+	if keyFunc == nil {
+		keyFunc = DefaultKey
+	}
+	file.Key = keyFunc(file)
+
+	sha, md := sha256.New(), md5.New()
+	body := &limitedReader{
+		r:         io.TeeReader(io.MultiReader(bytes.NewReader(head), part), io.MultiWriter(sha, md)),
+		fileLimit: config.MaxFileSize,
+		total:     total,
+		maxTotal:  config.MaxTotalSize,
+	}
+	location, err := config.Storage.Save(ctx, file.Key, body, file.ContentType)
+	if body.err != nil {
+		return nil, body.err
+	}
+	if err != nil {
+		return nil, err
+	}
+	file.Size = body.n
+	file.SHA256 = hexSum(sha)
+	file.MD5 = hexSum(md)
+	file.Location = location
+	return file, nil
+}
+
+func hexSum(h hash.Hash) string {
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (config *Config) allowed(contentType string) bool {
+	if len(config.AllowedTypes) == 0 {
+		return true
+	}
+	media, _, _ := mime.ParseMediaType(contentType)
+	for _, allowed := range config.AllowedTypes {
+		if allowed == media || (strings.HasSuffix(allowed, "/*") && strings.HasPrefix(media, allowed[:len(allowed)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+func (config *Config) cleanup(ctx context.Context, result *Result) {
+	for _, file := range result.Files {
+		config.Storage.Delete(ctx, file.Key)
+	}
+}
+
+// limitedReader fails with ErrFileTooLarge or ErrTotalTooLarge once a limit is passed
+type limitedReader struct {
+	r         io.Reader
+	n         int64
+	fileLimit int64
+	total     int64
+	maxTotal  int64
+	err       error
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.err != nil {
+		return 0, l.err
+	}
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	switch {
+	case l.fileLimit > 0 && l.n > l.fileLimit:
+		l.err = ErrFileTooLarge
+	case l.maxTotal > 0 && l.total+l.n > l.maxTotal:
+		l.err = ErrTotalTooLarge
+	}
+	if l.err != nil {
+		return 0, l.err
+	}
+	return n, err
+}
@@ -0,0 +1,491 @@
+package xrestful
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/douyu/jupiter/pkg/xlog"
+	restful "github.com/emicklei/go-restful/v3"
+)
+
+const (
+	// HeaderRequestID ...
+	HeaderRequestID = "X-Request-ID"
+
+	attrPrincipal = "xrestful.principal"
+	redacted      = "***"
+)
+
+// AuditRecord is one entry of the audit trail. Records are chained: Hash covers
+// the record and PrevHash, the Hash of the previous record, so a deleted or
+// modified record breaks the chain, see VerifyAuditChain. The hash is not keyed,
+// whoever can delete the last records can also rewrite them with valid hashes:
+// only the records before a head kept elsewhere, e.g. a logged Hash, are tamper-evident.
+type AuditRecord struct {
+	Seq       uint64    `json:"seq"`
+	Time      time.Time `json:"time"`
+	Principal string    `json:"principal"`
+	// 请求方法
+	Action string `json:"action"`
+	// 请求路径
+	Resource string `json:"resource"`
+	// 路由模板
+	Route     string `json:"route"`
+	RequestID string `json:"requestId"`
+	ClientIP  string `json:"clientIp"`
+	Status    int    `json:"status"`
+	// 脱敏后的JSON请求体
+	Body json.RawMessage `json:"body,omitempty"`
+	// 配置Snapshot时, 请求前后资源的差异
+	Diff     []AuditChange `json:"diff,omitempty"`
+	PrevHash string        `json:"prevHash"`
+	Hash     string        `json:"hash"`
+}
+
+// AuditChange is a changed field of the audited resource, nested fields are dotted
+type AuditChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// AuditSink stores audit records, Write is called by a single goroutine in chain order
+type AuditSink interface {
+	Write(record *AuditRecord) error
+}
+
+// AuditChainHead is implemented by sinks that can return their last record,
+// the chain then continues after it when the server restarts. A Write failing
+// after another writer advanced the head, e.g. another instance sharing the
+// table, is retried after the new head.
+type AuditChainHead interface {
+	LastAudit() (*AuditRecord, error)
+}
+
+// PrincipalFunc returns who sent the request
+type PrincipalFunc func(req *restful.Request) string
+
+// SetPrincipal is called by authentication filters to name who sent req
+func SetPrincipal(req *restful.Request, principal string) {
+	req.SetAttribute(attrPrincipal, principal)
+}
+
+// Principal returns the principal set by SetPrincipal
+func Principal(req *restful.Request) string {
+	principal, _ := req.Attribute(attrPrincipal).(string)
+	return principal
+}
+
+// JWTClaimPrincipal reads claim from the payload of the Bearer token. The signature
+// is NOT checked, a filter verifying the token must run before the audit filter.
+func JWTClaimPrincipal(claim string) PrincipalFunc {
+	return func(req *restful.Request) string {
+		token := strings.TrimSpace(strings.TrimPrefix(req.HeaderParameter("Authorization"), "Bearer "))
+		parts := strings.Split(token, ".")
+		if len(parts) != 3 {
+			return ""
+		}
+		payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+		if err != nil {
+			return ""
+		}
+		var claims map[string]interface{}
+		if err := json.Unmarshal(payload, &claims); err != nil {
+			return ""
+		}
+		if v, ok := claims[claim]; ok && v != nil {
+			return fmt.Sprint(v)
+		}
+		return ""
+	}
+}
+
+// AuditConfig audit trail filter options
+type AuditConfig struct {
+	// 需要审计的方法, 默认 POST PUT PATCH DELETE
+	Methods []string
+	// 需要审计的路由, 格式为 "GET /users/{id}" 或 "/users/{id}", 与Methods任一匹配即记录
+	Routes []string
+	// 需要脱敏的字段名, 不区分大小写, 默认 password token secret
+	RedactFields []string
+	// 记录的请求体上限, 超过时不记录请求体, 默认64KB
+	MaxBodySize int
+	// 异步队列长度, 队列满时丢弃记录并告警, 默认1024
+	QueueSize int
+	// 获取操作者, 默认为SetPrincipal设置的值
+	Principal PrincipalFunc
+	// 获取请求修改的资源, 请求前后各调用一次, 用于记录差异
+	Snapshot func(req *restful.Request) (interface{}, error)
+	// 审计记录存储
+	Sink AuditSink
+
+	logger *xlog.Logger
+	queue  chan *AuditRecord
+	done   chan struct{}
+	close  sync.Once
+	// 保护closed, 关闭后过滤器不再写入队列
+	mu      sync.RWMutex
+	closed  bool
+	dropped uint64
+	routes  map[string]bool
+	redact  map[string]bool
+	seq     uint64
+	prev    string
+}
+
+// DefaultAuditConfig ...
+func DefaultAuditConfig() *AuditConfig {
+	return &AuditConfig{
+		Methods:      []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete},
+		RedactFields: []string{"password", "token", "secret"},
+		MaxBodySize:  64 << 10,
+		QueueSize:    1024,
+		Principal:    Principal,
+		logger:       xlog.JupiterLogger.With(xlog.FieldMod(ModName)),
+	}
+}
+
+// WithSink ...
+func (config *AuditConfig) WithSink(sink AuditSink) *AuditConfig {
+	config.Sink = sink
+	return config
+}
+
+// WithRoutes ...
+func (config *AuditConfig) WithRoutes(routes ...string) *AuditConfig {
+	config.Routes = routes
+	return config
+}
+
+// WithPrincipal ...
+func (config *AuditConfig) WithPrincipal(fn PrincipalFunc) *AuditConfig {
+	config.Principal = fn
+	return config
+}
+
+// WithSnapshot ...
+func (config *AuditConfig) WithSnapshot(fn func(req *restful.Request) (interface{}, error)) *AuditConfig {
+	config.Snapshot = fn
+	return config
+}
+
+// WithRedactFields ...
+func (config *AuditConfig) WithRedactFields(fields ...string) *AuditConfig {
+	config.RedactFields = fields
+	return config
+}
+
+// WithLogger ...
+func (config *AuditConfig) WithLogger(logger *xlog.Logger) *AuditConfig {
+	config.logger = logger
+	return config
+}
+
+// Build starts the sink writer and create the audit filter, call Close after the server stopped
+func (config *AuditConfig) Build() restful.FilterFunction {
+	if config.logger == nil {
+		config.logger = xlog.JupiterLogger.With(xlog.FieldMod(ModName))
+	}
+	if config.Sink == nil {
+		config.logger.Panic("audit sink is required")
+	}
+	if config.Principal == nil {
+		config.Principal = Principal
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = 1024
+	}
+	config.routes = make(map[string]bool, len(config.Routes))
+	for _, route := range config.Routes {
+		config.routes[strings.TrimSpace(route)] = true
+	}
+	config.redact = make(map[string]bool, len(config.RedactFields))
+	for _, field := range config.RedactFields {
+		config.redact[strings.ToLower(field)] = true
+	}
+	if head, ok := config.Sink.(AuditChainHead); ok {
+		last, err := head.LastAudit()
+		if err != nil {
+			config.logger.Panic("load last audit record", xlog.FieldErr(err))
+		}
+		if last != nil {
+			config.seq, config.prev = last.Seq, last.Hash
+		}
+	}
+	config.queue = make(chan *AuditRecord, config.QueueSize)
+	config.done = make(chan struct{})
+	go config.run()
+	return config.filter
+}
+
+// Close writes the queued records then stops the writer, requests still
+// in flight are no longer audited
+func (config *AuditConfig) Close() {
+	config.close.Do(func() {
+		config.mu.Lock()
+		config.closed = true
+		close(config.queue)
+		config.mu.Unlock()
+		<-config.done
+	})
+}
+
+// Dropped returns the number of records dropped because the queue was full
+func (config *AuditConfig) Dropped() uint64 {
+	return atomic.LoadUint64(&config.dropped)
+}
+
+// enqueue never blocks the request, the record is dropped when the queue is full
+func (config *AuditConfig) enqueue(record *AuditRecord) {
+	config.mu.RLock()
+	defer config.mu.RUnlock()
+	if config.closed {
+		return
+	}
+	select {
+	case config.queue <- record:
+	default:
+		atomic.AddUint64(&config.dropped, 1)
+		config.logger.Warn("audit queue full, record dropped",
+			xlog.FieldMethod(record.Action), xlog.String("path", record.Resource), xlog.String("principal", record.Principal))
+	}
+}
+
+func (config *AuditConfig) audited(method, path string) bool {
+	return containsMethod(config.Methods, method) || config.routes[routeKey(method, path)] || config.routes[path]
+}
+
+func (config *AuditConfig) filter(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+	route := req.SelectedRoutePath()
+	if !config.audited(req.Request.Method, route) {
+		chain.ProcessFilter(req, resp)
+		return
+	}
+	body := config.readBody(req.Request)
+	var before interface{}
+	if config.Snapshot != nil {
+		var err error
+		if before, err = config.Snapshot(req); err != nil {
+			config.logger.Warn("audit snapshot", xlog.FieldErr(err), xlog.String("path", route))
+		}
+	}
+
+	chain.ProcessFilter(req, resp)
+
+	record := &AuditRecord{
+		Time:      time.Now().UTC().Truncate(time.Microsecond),
+		Principal: config.Principal(req),
+		Action:    req.Request.Method,
+		Resource:  req.Request.URL.Path,
+		Route:     route,
+		RequestID: req.HeaderParameter(HeaderRequestID),
+		ClientIP:  ClientIP(req),
+		Status:    resp.StatusCode(),
+		Body:      body,
+	}
+	if config.Snapshot != nil && record.Status < http.StatusBadRequest {
+		after, err := config.Snapshot(req)
+		if err != nil {
+			config.logger.Warn("audit snapshot", xlog.FieldErr(err), xlog.String("path", route))
+		} else {
+			record.Diff = config.diff(before, after)
+		}
+	}
+	config.enqueue(record)
+}
+
+// readBody returns the redacted JSON body, the body is restored for the handler
+func (config *AuditConfig) readBody(r *http.Request) json.RawMessage {
+	max := int64(config.MaxBodySize)
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength > max {
+		return nil
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, max+1))
+	// 未读完的部分接在后面, handler 仍能读到完整的请求体
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	if err != nil || len(body) == 0 || int64(len(body)) > max {
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return nil
+	}
+	out, err := json.Marshal(config.redactValue(v))
+	if err != nil {
+		return nil
+	}
+	return out
+}
+
+func (config *AuditConfig) redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if config.redact[strings.ToLower(key)] {
+				v[key] = redacted
+			} else {
+				v[key] = config.redactValue(value)
+			}
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = config.redactValue(value)
+		}
+	}
+	return v
+}
+
+// diff compares the JSON forms of before and after field by field
+func (config *AuditConfig) diff(before, after interface{}) []AuditChange {
+	prev, next := flattenJSON(before), flattenJSON(after)
+	fields := make(map[string]bool, len(prev)+len(next))
+	for field := range prev {
+		fields[field] = true
+	}
+	for field := range next {
+		fields[field] = true
+	}
+	var changes []AuditChange
+	for field := range fields {
+		o, n := prev[field], next[field]
+		if jsonEqual(o, n) {
+			continue
+		}
+		if config.redactedField(field) {
+			o, n = redacted, redacted
+		}
+		changes = append(changes, AuditChange{Field: field, Old: o, New: n})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes
+}
+
+func (config *AuditConfig) redactedField(field string) bool {
+	for _, name := range strings.Split(field, ".") {
+		if config.redact[strings.ToLower(name)] {
+			return true
+		}
+	}
+	return false
+}
+
+func flattenJSON(v interface{}) map[string]interface{} {
+	out := make(map[string]interface{})
+	if v == nil {
+		return out
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return out
+	}
+	var tree interface{}
+	if err := json.Unmarshal(b, &tree); err != nil {
+		return out
+	}
+	var walk func(prefix string, v interface{})
+	walk = func(prefix string, v interface{}) {
+		m, ok := v.(map[string]interface{})
+		if !ok || len(m) == 0 {
+			out[prefix] = v
+			return
+		}
+		for key, value := range m {
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			walk(key, value)
+		}
+	}
+	walk("", tree)
+	return out
+}
+
+func jsonEqual(a, b interface{}) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return bytes.Equal(x, y)
+}
+
+// run chains the queued records and writes them in order
+func (config *AuditConfig) run() {
+	defer close(config.done)
+	for record := range config.queue {
+		if err := config.write(record); err != nil {
+			// 写入失败的记录不进入链, 下一条记录接在上一条成功的记录之后
+			config.logger.Error("write audit record", xlog.FieldErr(err),
+				xlog.FieldMethod(record.Action), xlog.String("path", record.Resource), xlog.String("principal", record.Principal))
+		}
+	}
+}
+
+// auditWriteRetries is how many times a record is chained again after another writer advanced the head
+const auditWriteRetries = 3
+
+// write chains record after the head and writes it, when the write fails and
+// the head of the sink moved the record is chained after the new head
+func (config *AuditConfig) write(record *AuditRecord) error {
+	for attempt := 0; ; attempt++ {
+		record.Seq = config.seq + 1
+		record.PrevHash = config.prev
+		record.Hash = AuditHash(record)
+		err := config.Sink.Write(record)
+		if err == nil {
+			config.seq, config.prev = record.Seq, record.Hash
+			return nil
+		}
+		head, ok := config.Sink.(AuditChainHead)
+		if !ok || attempt >= auditWriteRetries {
+			return err
+		}
+		last, lerr := head.LastAudit()
+		if lerr != nil || last == nil || last.Seq == config.seq {
+			// 链头没有变化, 不是与其他写入者的冲突
+			return err
+		}
+		config.seq, config.prev = last.Seq, last.Hash
+	}
+}
+
+// AuditHash returns the chain hash of record, the sha256 of its JSON form without Hash
+func AuditHash(record *AuditRecord) string {
+	r := *record
+	r.Hash = ""
+	b, _ := json.Marshal(&r)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyAuditChain checks the hashes and links of records ordered by Seq,
+// the error names the first record that was modified or follows a deleted one.
+// Records removed from the end of the chain are not detected, compare the last
+// Hash with a head kept elsewhere.
+func VerifyAuditChain(records []*AuditRecord) error {
+	for i, record := range records {
+		if AuditHash(record) != record.Hash {
+			return fmt.Errorf("audit record %d modified", record.Seq)
+		}
+		if i == 0 {
+			continue
+		}
+		prev := records[i-1]
+		if record.PrevHash != prev.Hash || record.Seq != prev.Seq+1 {
+			return fmt.Errorf("audit records missing between %d and %d", prev.Seq, record.Seq)
+		}
+	}
+	return nil
+}
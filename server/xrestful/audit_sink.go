@@ -0,0 +1,86 @@
+package xrestful
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// FileAuditSink appends records as JSON lines
+type FileAuditSink struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// NewFileAuditSink opens or creates the file at path
+func NewFileAuditSink(path string) (*FileAuditSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return nil, err
+	}
+	return &FileAuditSink{path: path, file: file}, nil
+}
+
+// Write implements AuditSink, the file is synced after each record
+func (s *FileAuditSink) Write(record *AuditRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+// LastAudit implements AuditChainHead
+func (s *FileAuditSink) LastAudit() (*AuditRecord, error) {
+	records, err := ReadAuditFile(s.path)
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+	return records[len(records)-1], nil
+}
+
+// Close ...
+func (s *FileAuditSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// ReadAuditFile reads the records of a FileAuditSink file, e.g. for VerifyAuditChain
+func ReadAuditFile(path string) ([]*AuditRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var records []*AuditRecord
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64<<10), 16<<20)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		record := new(AuditRecord)
+		if err := json.Unmarshal(scanner.Bytes(), record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// ChanAuditSink sends records to a channel, e.g. to forward them to a message queue
+type ChanAuditSink chan<- *AuditRecord
+
+// Write implements AuditSink, it blocks until the record is received
+func (s ChanAuditSink) Write(record *AuditRecord) error {
+	s <- record
+	return nil
+}
@@ -0,0 +1,211 @@
+package xrestful
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	restful "github.com/emicklei/go-restful/v3"
+)
+
+func TestAudit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := NewFileAuditSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	users := map[string]map[string]interface{}{"1": {"name": "a", "password": "x"}}
+	audit := DefaultAuditConfig().WithSink(sink).
+		WithSnapshot(func(req *restful.Request) (interface{}, error) {
+			user := make(map[string]interface{})
+			for k, v := range users[req.PathParameter("id")] {
+				user[k] = v
+			}
+			return user, nil
+		})
+	container := restful.NewContainer()
+	container.Filter(func(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+		SetPrincipal(req, "alice")
+		chain.ProcessFilter(req, resp)
+	})
+	container.Filter(audit.Build())
+	ws := new(restful.WebService)
+	ws.Route(ws.PUT("/users/{id}").To(func(req *restful.Request, resp *restful.Response) {
+		var body map[string]interface{}
+		req.ReadEntity(&body)
+		for k, v := range body {
+			users[req.PathParameter("id")][k] = v
+		}
+		resp.WriteHeader(http.StatusNoContent)
+	}))
+	ws.Route(ws.GET("/users/{id}").To(func(req *restful.Request, resp *restful.Response) {}))
+	container.Add(ws)
+
+	for _, body := range []string{`{"name": "b", "password": "y"}`, `{"name": "c"}`} {
+		r := httptest.NewRequest(http.MethodPut, "/users/1", strings.NewReader(body))
+		r.Header.Set("Content-Type", MIMEApplicationJSON)
+		r.Header.Set(HeaderRequestID, "req-1")
+		container.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	container.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	audit.Close()
+
+	records, err := ReadAuditFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("%d records", len(records))
+	}
+	first := records[0]
+	if first.Principal != "alice" || first.Action != http.MethodPut || first.Route != "/users/{id}" ||
+		first.RequestID != "req-1" || first.Status != http.StatusNoContent {
+		t.Fatalf("record = %+v", first)
+	}
+	var body map[string]interface{}
+	json.Unmarshal(first.Body, &body)
+	if body["password"] != redacted {
+		t.Errorf("body = %s", first.Body)
+	}
+	diff, _ := json.Marshal(first.Diff)
+	if string(diff) != `[{"field":"name","old":"a","new":"b"},{"field":"password","old":"***","new":"***"}]` {
+		t.Errorf("diff = %s", diff)
+	}
+	if err := VerifyAuditChain(records); err != nil {
+		t.Fatal(err)
+	}
+
+	records[0].Principal = "mallory"
+	if err := VerifyAuditChain(records); err == nil {
+		t.Error("modified record not detected")
+	}
+	records[0].Principal = "alice"
+	if err := VerifyAuditChain(records[1:]); err != nil {
+		t.Error(err)
+	}
+
+	// 重启后从文件的最后一条记录接续链
+	next := DefaultAuditConfig().WithSink(sink)
+	next.Build()
+	next.queue <- &AuditRecord{Action: http.MethodDelete}
+	next.Close()
+	if records, err = ReadAuditFile(path); err != nil || len(records) != 3 {
+		t.Fatalf("%d records, %v", len(records), err)
+	}
+	if err := VerifyAuditChain(records); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAuditReadBodyLimit(t *testing.T) {
+	config := DefaultAuditConfig()
+	config.MaxBodySize = 16
+	body := `{"name": "` + strings.Repeat("x", 64) + `"}`
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	// 分块传输, 没有 Content-Length
+	r.ContentLength = -1
+	if got := config.readBody(r); got != nil {
+		t.Errorf("body over the limit recorded: %s", got)
+	}
+	rest, _ := ioutil.ReadAll(r.Body)
+	if string(rest) != body {
+		t.Errorf("handler body = %q", rest)
+	}
+}
+
+// blockingSink holds every Write until release is closed
+type blockingSink struct {
+	release chan struct{}
+	mu      sync.Mutex
+	records []*AuditRecord
+}
+
+func (s *blockingSink) Write(record *AuditRecord) error {
+	<-s.release
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, record)
+	return nil
+}
+
+func TestAuditQueueFull(t *testing.T) {
+	sink := &blockingSink{release: make(chan struct{})}
+	audit := DefaultAuditConfig().WithSink(sink)
+	audit.QueueSize = 1
+	container := restful.NewContainer()
+	container.Filter(audit.Build())
+	ws := new(restful.WebService)
+	ws.Route(ws.POST("/items").To(func(req *restful.Request, resp *restful.Response) {}))
+	container.Add(ws)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 5; i++ {
+			container.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/items", nil))
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("requests blocked on a slow sink")
+	}
+	if n := audit.Dropped(); n < 3 {
+		t.Fatalf("dropped %d", n)
+	}
+	close(sink.release)
+	audit.Close()
+	written := len(sink.records)
+
+	// 关闭后的请求不再审计, 也不会写入已关闭的队列
+	container.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/items", nil))
+	if written == 0 || len(sink.records) != written {
+		t.Fatalf("written %d then %d", written, len(sink.records))
+	}
+}
+
+// sharedSink is a table shared by several writers, seq is its primary key
+type sharedSink struct {
+	records []*AuditRecord
+}
+
+func (s *sharedSink) Write(record *AuditRecord) error {
+	if record.Seq != uint64(len(s.records))+1 {
+		return errors.New("duplicate seq")
+	}
+	r := *record
+	s.records = append(s.records, &r)
+	return nil
+}
+
+func (s *sharedSink) LastAudit() (*AuditRecord, error) {
+	if len(s.records) == 0 {
+		return nil, nil
+	}
+	return s.records[len(s.records)-1], nil
+}
+
+func TestAuditSharedSink(t *testing.T) {
+	sink := &sharedSink{}
+	a, b := &AuditConfig{Sink: sink}, &AuditConfig{Sink: sink}
+	for i, config := range []*AuditConfig{a, b, a, a, b} {
+		if err := config.write(&AuditRecord{Action: http.MethodPost, Status: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(sink.records) != 5 {
+		t.Fatalf("%d records", len(sink.records))
+	}
+	if err := VerifyAuditChain(sink.records); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,257 @@
+package xrestful
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/douyu/jupiter/pkg/metric"
+	"github.com/douyu/jupiter/pkg/xlog"
+	restful "github.com/emicklei/go-restful/v3"
+	"golang.org/x/sync/singleflight"
+)
+
+// CachedResponse is a full response kept by a CacheStore
+type CachedResponse struct {
+	Status       int         `json:"status"`
+	Header       http.Header `json:"header"`
+	Body         []byte      `json:"body"`
+	ETag         string      `json:"etag"`
+	LastModified time.Time   `json:"lastModified"`
+}
+
+// CacheStore stores cached responses, see NewMemoryCacheStore and NewRedisCacheStore
+type CacheStore interface {
+	// Get returns the entry for key, found is false if it does not exist or is expired
+	Get(key string) (entry *CachedResponse, found bool, err error)
+	// Set saves the entry for key with the given ttl
+	Set(key string, entry *CachedResponse, ttl time.Duration) error
+	// Delete removes key, deleting a missing key is not an error
+	Delete(key string) error
+}
+
+// CacheConfig response cache options of one route
+type CacheConfig struct {
+	// 缓存时间, 同时作为Cache-Control的max-age
+	TTL time.Duration
+	// 参与缓存key计算的请求头, 同时写入Vary响应头
+	Vary []string
+	// 自定义缓存key, 默认为 method + host + uri
+	KeyFunc func(req *restful.Request) string
+	// 缓存存储, 默认为1024条的内存LRU
+	Store CacheStore
+	// Cache-Control 使用private
+	Private bool
+
+	logger *xlog.Logger
+	group  singleflight.Group
+}
+
+// DefaultCacheConfig ...
+func DefaultCacheConfig() *CacheConfig {
+	return &CacheConfig{
+		TTL:    time.Minute,
+		logger: xlog.JupiterLogger.With(xlog.FieldMod(ModName)),
+	}
+}
+
+// WithTTL ...
+func (config *CacheConfig) WithTTL(ttl time.Duration) *CacheConfig {
+	config.TTL = ttl
+	return config
+}
+
+// WithVary ...
+func (config *CacheConfig) WithVary(header ...string) *CacheConfig {
+	config.Vary = append(config.Vary, header...)
+	return config
+}
+
+// WithKeyFunc ...
+func (config *CacheConfig) WithKeyFunc(fn func(req *restful.Request) string) *CacheConfig {
+	config.KeyFunc = fn
+	return config
+}
+
+// WithStore ...
+func (config *CacheConfig) WithStore(store CacheStore) *CacheConfig {
+	config.Store = store
+	return config
+}
+
+// WithLogger ...
+func (config *CacheConfig) WithLogger(logger *xlog.Logger) *CacheConfig {
+	config.logger = logger
+	return config
+}
+
+// Build create the cache filter, attach it to a route with RouteBuilder.Filter
+func (config *CacheConfig) Build() restful.FilterFunction {
+	if config.Store == nil {
+		config.Store = NewMemoryCacheStore(1024)
+	}
+	if config.logger == nil {
+		config.logger = xlog.JupiterLogger.With(xlog.FieldMod(ModName))
+	}
+	return config.filter
+}
+
+// CacheFilter create a cache filter with ttl and default options
+func CacheFilter(ttl time.Duration, vary ...string) restful.FilterFunction {
+	return DefaultCacheConfig().WithTTL(ttl).WithVary(vary...).Build()
+}
+
+func (config *CacheConfig) filter(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+	method := req.Request.Method
+	if method != http.MethodGet && method != http.MethodHead {
+		chain.ProcessFilter(req, resp)
+		return
+	}
+	name := req.SelectedRoutePath()
+	key := config.key(req)
+
+	if !hasCacheDirective(req.Request.Header.Get("Cache-Control"), "no-cache") {
+		entry, found, err := config.Store.Get(key)
+		if err != nil {
+			config.logger.Error("cache get", xlog.FieldErr(err), xlog.FieldKey(key))
+		}
+		if found {
+			metric.CacheHandleCounter.Inc(metric.TypeHTTP, name, "get", metric.CodeCacheHit)
+			config.writeEntry(resp, req.Request, entry, true)
+			return
+		}
+	}
+	metric.CacheHandleCounter.Inc(metric.TypeHTTP, name, "get", metric.CodeCacheMiss)
+
+	// 缓存未命中时合并相同key的请求, 只有一个请求回源
+	var leader bool
+	v, _, _ := config.group.Do(key, func() (interface{}, error) {
+		leader = true
+		rec := recordResponse(resp, func() {
+			chain.ProcessFilter(req, resp)
+		})
+		fill := &cacheFill{entry: newCachedResponse(rec), stored: cacheable(rec)}
+		if fill.stored {
+			if err := config.Store.Set(key, fill.entry, config.TTL); err != nil {
+				config.logger.Error("cache set", xlog.FieldErr(err), xlog.FieldKey(key))
+			}
+		}
+		return fill, nil
+	})
+	fill := v.(*cacheFill)
+	switch {
+	case leader:
+		// 状态码和长度已经由handler记入resp, 直接写入底层writer
+		config.writeEntry(resp.ResponseWriter, req.Request, fill.entry, fill.stored)
+	case fill.stored:
+		config.writeEntry(resp, req.Request, fill.entry, true)
+	default:
+		// 不可缓存的响应可能带有用户数据, 不能共享给其他请求
+		chain.ProcessFilter(req, resp)
+	}
+}
+
+type cacheFill struct {
+	entry  *CachedResponse
+	stored bool
+}
+
+func (config *CacheConfig) writeEntry(w http.ResponseWriter, r *http.Request, entry *CachedResponse, cached bool) {
+	header := w.Header()
+	copyHeader(header, entry.Header)
+	if cached {
+		config.setCacheHeader(header, entry)
+		if notModified(r, entry) {
+			header.Del(HeaderContentType)
+			header.Del("Content-Length")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	w.WriteHeader(entry.Status)
+	if r.Method != http.MethodHead {
+		w.Write(entry.Body)
+	}
+}
+
+func (config *CacheConfig) setCacheHeader(header http.Header, entry *CachedResponse) {
+	scope := "public"
+	if config.Private {
+		scope = "private"
+	}
+	header.Set("Cache-Control", fmt.Sprintf("%s, max-age=%d", scope, int64(config.TTL/time.Second)))
+	header.Set("ETag", entry.ETag)
+	header.Set("Last-Modified", entry.LastModified.UTC().Format(http.TimeFormat))
+	if len(config.Vary) > 0 {
+		header.Set("Vary", strings.Join(config.Vary, ", "))
+	}
+}
+
+func (config *CacheConfig) key(req *restful.Request) string {
+	var key string
+	if config.KeyFunc != nil {
+		key = config.KeyFunc(req)
+	} else {
+		key = req.Request.Method + " " + req.Request.Host + req.Request.URL.RequestURI()
+	}
+	for _, h := range config.Vary {
+		key += "|" + req.Request.Header.Get(h)
+	}
+	return key
+}
+
+func newCachedResponse(rec *responseRecorder) *CachedResponse {
+	sum := sha256.Sum256(rec.body.Bytes())
+	return &CachedResponse{
+		Status:       rec.StatusCode(),
+		Header:       rec.Header(),
+		Body:         rec.body.Bytes(),
+		ETag:         `"` + hex.EncodeToString(sum[:16]) + `"`,
+		LastModified: time.Now().Truncate(time.Second),
+	}
+}
+
+// cacheable only 200 responses without cookies or no-store are cached
+func cacheable(rec *responseRecorder) bool {
+	if rec.StatusCode() != http.StatusOK {
+		return false
+	}
+	if rec.Header().Get("Set-Cookie") != "" {
+		return false
+	}
+	cc := rec.Header().Get("Cache-Control")
+	return !hasCacheDirective(cc, "no-store") && !hasCacheDirective(cc, "private")
+}
+
+// notModified evaluates If-None-Match, then If-Modified-Since (RFC 7232 section 6)
+func notModified(r *http.Request, entry *CachedResponse) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == entry.ETag {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		t, err := http.ParseTime(ims)
+		if err != nil {
+			return false
+		}
+		return !entry.LastModified.After(t)
+	}
+	return false
+}
+
+func hasCacheDirective(cacheControl, directive string) bool {
+	for _, d := range strings.Split(cacheControl, ",") {
+		if strings.EqualFold(strings.TrimSpace(d), directive) {
+			return true
+		}
+	}
+	return false
+}
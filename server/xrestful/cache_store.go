@@ -0,0 +1,140 @@
+package xrestful
+
+import (
+	"container/list"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/douyu/jupiter/pkg/client/redis"
+	goredis "github.com/go-redis/redis"
+)
+
+// MemoryCacheStore is an in-process LRU CacheStore
+type MemoryCacheStore struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type memoryCacheItem struct {
+	key        string
+	entry      *CachedResponse
+	expiration time.Time
+}
+
+// NewMemoryCacheStore returns a LRU store holding at most size entries
+func NewMemoryCacheStore(size int) *MemoryCacheStore {
+	return &MemoryCacheStore{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// Get implements CacheStore
+func (m *MemoryCacheStore) Get(key string) (*CachedResponse, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	el, ok := m.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	item := el.Value.(*memoryCacheItem)
+	if time.Now().After(item.expiration) {
+		m.removeElement(el)
+		return nil, false, nil
+	}
+	m.ll.MoveToFront(el)
+	return item.entry, true, nil
+}
+
+// Set implements CacheStore
+func (m *MemoryCacheStore) Set(key string, entry *CachedResponse, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if el, ok := m.items[key]; ok {
+		m.ll.MoveToFront(el)
+		item := el.Value.(*memoryCacheItem)
+		item.entry, item.expiration = entry, time.Now().Add(ttl)
+		return nil
+	}
+	m.items[key] = m.ll.PushFront(&memoryCacheItem{key: key, entry: entry, expiration: time.Now().Add(ttl)})
+	for m.size > 0 && m.ll.Len() > m.size {
+		m.removeElement(m.ll.Back())
+	}
+	return nil
+}
+
+// Delete implements CacheStore
+func (m *MemoryCacheStore) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if el, ok := m.items[key]; ok {
+		m.removeElement(el)
+	}
+	return nil
+}
+
+// Len returns the number of cached entries, including expired ones not yet evicted
+func (m *MemoryCacheStore) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.ll.Len()
+}
+
+func (m *MemoryCacheStore) removeElement(el *list.Element) {
+	m.ll.Remove(el)
+	delete(m.items, el.Value.(*memoryCacheItem).key)
+}
+
+// RedisCacheStore is a CacheStore shared by all instances through redis
+type RedisCacheStore struct {
+	redis  *redis.Redis
+	prefix string
+}
+
+// NewRedisCacheStore returns a RedisCacheStore with the "xrestful:cache:" key prefix
+func NewRedisCacheStore(redis *redis.Redis) *RedisCacheStore {
+	return NewRedisCacheStoreWithPrefix(redis, "xrestful:cache:")
+}
+
+// NewRedisCacheStoreWithPrefix returns a RedisCacheStore, prefix avoids naming clashes
+func NewRedisCacheStoreWithPrefix(redis *redis.Redis, prefix string) *RedisCacheStore {
+	return &RedisCacheStore{
+		redis:  redis,
+		prefix: prefix,
+	}
+}
+
+// Get implements CacheStore
+func (r *RedisCacheStore) Get(key string) (*CachedResponse, bool, error) {
+	b, err := r.redis.GetRaw(r.prefix + key)
+	if err == goredis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var entry CachedResponse
+	if err := json.Unmarshal(b, &entry); err != nil {
+		return nil, false, err
+	}
+	return &entry, true, nil
+}
+
+// Set implements CacheStore
+func (r *RedisCacheStore) Set(key string, entry *CachedResponse, ttl time.Duration) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return r.redis.SetWithErr(r.prefix+key, b, ttl)
+}
+
+// Delete implements CacheStore
+func (r *RedisCacheStore) Delete(key string) error {
+	_, err := r.redis.DelWithErr(r.prefix + key)
+	return err
+}
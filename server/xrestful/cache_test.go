@@ -0,0 +1,104 @@
+package xrestful
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	restful "github.com/emicklei/go-restful/v3"
+)
+
+func TestCacheFilter(t *testing.T) {
+	var calls int
+	ws := new(restful.WebService)
+	ws.Route(ws.GET("/items").
+		Filter(CacheFilter(time.Minute)).
+		To(func(req *restful.Request, resp *restful.Response) {
+			calls++
+			resp.WriteErrorString(http.StatusOK, "items")
+		}))
+	container := restful.NewContainer()
+	container.Add(ws)
+
+	rec := httptest.NewRecorder()
+	container.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/items", nil))
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || rec.Body.String() != "items" || etag == "" {
+		t.Fatalf("miss: code=%d body=%q etag=%q", rec.Code, rec.Body.String(), etag)
+	}
+
+	rec = httptest.NewRecorder()
+	container.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/items", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "items" || calls != 1 {
+		t.Fatalf("hit: code=%d body=%q calls=%d", rec.Code, rec.Body.String(), calls)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/items", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	container.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Fatalf("conditional: code=%d body=%q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestMemoryCacheStoreEvict(t *testing.T) {
+	store := NewMemoryCacheStore(2)
+	for _, key := range []string{"a", "b", "c"} {
+		store.Set(key, &CachedResponse{Status: http.StatusOK}, time.Minute)
+	}
+	if _, found, _ := store.Get("a"); found {
+		t.Fatal("a should be evicted")
+	}
+	if store.Len() != 2 {
+		t.Fatalf("len = %d", store.Len())
+	}
+	store.Set("d", &CachedResponse{}, -time.Second)
+	if _, found, _ := store.Get("d"); found {
+		t.Fatal("d should be expired")
+	}
+}
+
+func TestCacheFilterCoalesce(t *testing.T) {
+	var calls int32
+	ws := new(restful.WebService)
+	ws.Route(ws.GET("/items").
+		Filter(CacheFilter(time.Minute)).
+		To(func(req *restful.Request, resp *restful.Response) {
+			n := atomic.AddInt32(&calls, 1)
+			time.Sleep(50 * time.Millisecond)
+			resp.WriteErrorString(http.StatusOK, fmt.Sprintf("items %d", n))
+		}))
+	container := restful.NewContainer()
+	container.Add(ws)
+
+	const n = 10
+	recs := make([]*httptest.ResponseRecorder, n)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := range recs {
+		recs[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func(rec *httptest.ResponseRecorder) {
+			defer wg.Done()
+			<-start
+			container.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/items", nil))
+		}(recs[i])
+	}
+	close(start)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Fatalf("origin ran %d times", calls)
+	}
+	etag := recs[0].Header().Get("ETag")
+	for i, rec := range recs {
+		if rec.Code != http.StatusOK || rec.Body.String() != "items 1" || etag == "" || rec.Header().Get("ETag") != etag {
+			t.Fatalf("request %d: code=%d body=%q etag=%q", i, rec.Code, rec.Body.String(), rec.Header().Get("ETag"))
+		}
+	}
+}
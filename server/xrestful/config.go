@@ -6,9 +6,13 @@ import (
 	"github.com/douyu/jupiter/pkg/constant"
 	"github.com/douyu/jupiter/pkg/ecode"
 	"github.com/douyu/jupiter/pkg/flag"
+	"github.com/douyu/jupiter/pkg/registry"
 	"github.com/douyu/jupiter/pkg/xlog"
 	restful "github.com/emicklei/go-restful/v3"
 	"github.com/pkg/errors"
+	"net"
+	"sync"
+	"sync/atomic"
 )
 
 //ModName named a mod
@@ -19,7 +23,19 @@ type Config struct {
 	// 绑定地址
 	Host string `json:"host" toml:"host"`
 	// 绑定端口
-	Port       int    `json:"port" toml:"port"`
+	Port int `json:"port" toml:"port"`
+	// 监听网络 tcp tcp4 tcp6 unix, 默认 tcp
+	Network string `json:"network" toml:"network"`
+	// unix socket 文件路径
+	SocketPath string `json:"socketPath" toml:"socketPath"`
+	// unix socket 文件权限, 八进制, 如 0660
+	SocketFileMode string `json:"socketFileMode" toml:"socketFileMode"`
+	// 开启SO_REUSEPORT, 新旧进程可同时监听同一端口, 用于平滑替换二进制
+	ReusePort bool `json:"reusePort" toml:"reusePort"`
+	// 优先使用systemd传入的监听(LISTEN_FDS)
+	SocketActivation bool `json:"socketActivation" toml:"socketActivation"`
+	// 按名称选取systemd传入的监听(LISTEN_FDNAMES), 为空时使用第一个未被使用的
+	SocketActivationName string `json:"socketActivationName" toml:"socketActivationName"`
 	Deployment string `json:"deployment" toml:"deployment"`
 	Debug      bool   `json:"debug" toml:"debug"`
 	// 测量请求响应时间
@@ -32,8 +48,46 @@ type Config struct {
 	ServiceAddress string `json:"serviceAddress" toml:"serviceAddress"`
 
 	SlowQueryThresholdInMilli int64 `json:"slowQueryThresholdInMilli" toml:"slowQueryThresholdInMilli"`
-
-	logger *xlog.Logger
+	// access log 采样比例 0~1, 错误和慢请求总是记录
+	AccessLogSampling float64 `json:"accessLogSampling" toml:"accessLogSampling"`
+	// 限流
+	RateLimit RateLimitConfig `json:"rateLimit" toml:"rateLimit"`
+	// 跨域
+	CORS CORSConfig `json:"cors" toml:"cors"`
+	// 禁用的路由, 格式为 "GET /users/{id}" 或 "/users/{id}", 请求返回503
+	DisabledRoutes []string `json:"disabledRoutes" toml:"disabledRoutes"`
+	// 可信代理的IP或CIDR, 只有可信代理转发的X-Forwarded-For和Forwarded才会被采用
+	TrustedProxies []string `json:"trustedProxies" toml:"trustedProxies"`
+	// IP 黑白名单
+	IPFilter IPFilterConfig `json:"ipFilter" toml:"ipFilter"`
+	// OpenAPI 3 文档, 设置后按文档校验请求, debug 模式下同时校验响应
+	OpenAPISpec string `json:"openAPISpec" toml:"openAPISpec"`
+	// 自适应并发限制
+	Limiter LimiterConfig `json:"limiter" toml:"limiter"`
+	// 流量镜像, 将采样的请求复制到影子服务
+	Mirror MirrorConfig `json:"mirror" toml:"mirror"`
+
+	// 注册中心中的权重, 默认100
+	Weight float64 `json:"weight" toml:"weight"`
+	// 为false时注册为不可用, 用于摘除节点, 默认true
+	Enable bool `json:"enable" toml:"enable"`
+	// 所在地域, 默认为应用的地域
+	Region string `json:"region" toml:"region"`
+	// 所在可用区, 默认为应用的可用区
+	Zone string `json:"zone" toml:"zone"`
+	// 服务版本, 写入注册信息的metadata.version
+	Version string `json:"version" toml:"version"`
+	// 自定义标签, 写入注册信息的metadata
+	Labels map[string]string `json:"labels" toml:"labels"`
+
+	logger    *xlog.Logger
+	container *restful.Container
+	listener  net.Listener
+	registry  registry.Registry
+	server    *Server
+	// 配置变更时更新, 见 dynamicFields
+	mu      sync.Mutex
+	runtime atomic.Value
 }
 
 // DefaultConfig ...
@@ -41,9 +95,14 @@ func DefaultConfig() *Config {
 	return &Config{
 		Host:                      flag.String("host"),
 		Port:                      9091,
+		Network:                   "tcp",
 		Debug:                     false,
 		Deployment:                constant.DefaultDeployment,
 		SlowQueryThresholdInMilli: 500, // 500ms
+		AccessLogSampling:         1,
+		Limiter:                   DefaultLimiterConfig(),
+		Weight:                    100,
+		Enable:                    true,
 		logger:                    xlog.JupiterLogger.With(xlog.FieldMod(ModName)),
 	}
 }
@@ -60,6 +119,7 @@ func RawConfig(key string) *Config {
 		errors.Cause(err) != conf.ErrInvalidKey {
 		config.logger.Panic("http server parse config panic", xlog.FieldErrKind(ecode.ErrKindUnmarshalConfigErr), xlog.FieldErr(err), xlog.FieldKey(key), xlog.FieldValueAny(config))
 	}
+	config.watch(key)
 	return config
 }
 
@@ -69,6 +129,18 @@ func (config *Config) WithLogger(logger *xlog.Logger) *Config {
 	return config
 }
 
+// WithContainer serve routes of container instead of restful.DefaultContainer
+func (config *Config) WithContainer(container *restful.Container) *Config {
+	config.container = container
+	return config
+}
+
+// WithListener serve on listener instead of listening on Address
+func (config *Config) WithListener(listener net.Listener) *Config {
+	config.listener = listener
+	return config
+}
+
 // WithHost ...
 func (config *Config) WithHost(host string) *Config {
 	config.Host = host
@@ -84,23 +156,71 @@ func (config *Config) WithPort(port int) *Config {
 // Build create server instance, then initialize it with necessary interceptor
 func (config *Config) Build() *Server {
 	server := newServer(config)
-	restful.DefaultContainer.Filter(recoverMiddleware(config.logger, config.SlowQueryThresholdInMilli))
+	config.server = server
+	config.container = server.container
+	config.runtime.Store(newRuntimeOptions(config))
+	config.installFilters(server.container)
+	return server
+}
+
+// installFilters adds the server filters to container, it is shared by the virtual hosts
+func (config *Config) installFilters(container *restful.Container) {
+	trusted, err := parseIPNets(config.TrustedProxies)
+	if err != nil {
+		config.logger.Panic("invalid trustedProxies", xlog.FieldErrKind(ecode.ErrKindUnmarshalConfigErr), xlog.FieldErr(err))
+	}
+	container.Filter(clientIPMiddleware(trusted))
+	container.Filter(recoverMiddleware(config))
+
+	if len(config.IPFilter.Allow) > 0 || len(config.IPFilter.Deny) > 0 || len(config.IPFilter.Routes) > 0 {
+		filter, err := ipFilterMiddleware(&config.IPFilter)
+		if err != nil {
+			config.logger.Panic("invalid ipFilter", xlog.FieldErrKind(ecode.ErrKindUnmarshalConfigErr), xlog.FieldErr(err))
+		}
+		container.Filter(filter)
+	}
+	container.Filter(corsMiddleware(config))
+	container.Filter(disabledRouteMiddleware(config))
+	container.Filter(rateLimitMiddleware(config))
+	container.Filter(negotiateMiddleware())
+
+	if config.OpenAPISpec != "" {
+		container.Filter(DefaultOpenAPIConfig().
+			WithSpecFile(config.OpenAPISpec).
+			WithValidateResponse(config.Debug).
+			WithLogger(config.logger).
+			Build())
+	}
+
+	if config.Limiter.Enable {
+		container.Filter(limiterMiddleware(&config.Limiter))
+	}
+
+	if config.Mirror.enabled() {
+		filter, err := mirrorMiddleware(&config.Mirror, config.logger)
+		if err != nil {
+			config.logger.Panic("invalid mirror", xlog.FieldErrKind(ecode.ErrKindUnmarshalConfigErr), xlog.FieldErr(err))
+		}
+		container.Filter(filter)
+	}
 
 	if !config.DisableMetric {
-		restful.DefaultContainer.Filter(metricServerInterceptor())
+		container.Filter(metricServerInterceptor())
 	}
 
 	if !config.DisableTrace {
-		restful.DefaultContainer.Filter(traceServerInterceptor())
+		container.Filter(traceServerInterceptor())
 	}
 
 	if config.EnableGzip {
-		restful.DefaultContainer.EnableContentEncoding(true)
+		container.EnableContentEncoding(true)
 	}
-	return server
 }
 
 // Address ...
 func (config *Config) Address() string {
+	if config.Network == "unix" {
+		return config.SocketPath
+	}
 	return fmt.Sprintf("%s:%d", config.Host, config.Port)
 }
@@ -9,6 +9,22 @@ const (
 	codeMSSecondItemNotError = 1004
 	codeMSResErr             = 1005
 )
+
+// filter codes
+const (
+	codeIdempotencyKeyMissing = 1100
+	codeIdempotencyInProgress = 1101
+	codeIdempotencyMismatch   = 1102
+	codeBodyTooLarge          = 1103
+	codeBodyUnreadable        = 1104
+	codeIPForbidden           = 1110
+	codeOverloaded            = 1111
+	codeRouteDisabled         = 1112
+	codeRateLimited           = 1113
+	codeVersionInvalid        = 1120
+	codeVersionUnsupported    = 1121
+	codeOpenAPIInvalid        = 1130
+)
 const (
 	// StatusContinue ...
 	StatusContinue = 100
@@ -187,6 +203,20 @@ const (
 	MIMEApplicationJSONCharsetUTF8 = MIMEApplicationJSON + "; " + charsetUTF8
 	// MIMEApplicationProtobuf ...
 	MIMEApplicationProtobuf = "application/protobuf"
+	// MIMEApplicationXProtobuf ...
+	MIMEApplicationXProtobuf = "application/x-protobuf"
+	// MIMEApplicationXML ...
+	MIMEApplicationXML = "application/xml"
+	// MIMETextXML ...
+	MIMETextXML = "text/xml"
+	// MIMEApplicationYAML ...
+	MIMEApplicationYAML = "application/x-yaml"
+	// MIMEApplicationMsgPack ...
+	MIMEApplicationMsgPack = "application/x-msgpack"
+	// MIMEApplicationMsgPack2 ...
+	MIMEApplicationMsgPack2 = "application/msgpack"
+	// MIMETextCSV ...
+	MIMETextCSV = "text/csv"
 )
 const (
 	charsetUTF8 = "charset=utf-8"
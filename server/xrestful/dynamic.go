@@ -0,0 +1,244 @@
+package xrestful
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+
+	"github.com/douyu/jupiter/pkg/conf"
+	"github.com/douyu/jupiter/pkg/xlog"
+	restful "github.com/emicklei/go-restful/v3"
+	"github.com/system18188/jupiter-plugin/server/xrestful/errcode"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+// RateLimitConfig token bucket rate limit options, requests over the limit get 429
+type RateLimitConfig struct {
+	// 整个server每秒请求数, 0表示不限制
+	QPS float64 `json:"qps" toml:"qps"`
+	// 突发请求数, 默认等于QPS
+	Burst int `json:"burst" toml:"burst"`
+	// 单个路由的限制, 与server限制同时生效
+	Routes []RouteRateLimit `json:"routes" toml:"routes"`
+}
+
+// RouteRateLimit rate limit of one route
+type RouteRateLimit struct {
+	// 为空时匹配所有方法
+	Method string `json:"method" toml:"method"`
+	// 路由模板, 如 /users/{id}
+	Path  string  `json:"path" toml:"path"`
+	QPS   float64 `json:"qps" toml:"qps"`
+	Burst int     `json:"burst" toml:"burst"`
+}
+
+// CORSConfig cross origin options
+type CORSConfig struct {
+	Enable bool `json:"enable" toml:"enable"`
+	// 允许的Origin, 支持正则, 为空时允许所有
+	AllowOrigins     []string `json:"allowOrigins" toml:"allowOrigins"`
+	AllowMethods     []string `json:"allowMethods" toml:"allowMethods"`
+	AllowHeaders     []string `json:"allowHeaders" toml:"allowHeaders"`
+	ExposeHeaders    []string `json:"exposeHeaders" toml:"exposeHeaders"`
+	AllowCredentials bool     `json:"allowCredentials" toml:"allowCredentials"`
+	// preflight 缓存秒数
+	MaxAge int `json:"maxAge" toml:"maxAge"`
+}
+
+// runtimeOptions are the Config fields that can change while serving,
+// they are replaced as a whole when the config source changes.
+type runtimeOptions struct {
+	slowQueryThresholdInMilli int64
+	debug                     bool
+	accessLogSampling         float64
+	limiter                   *rate.Limiter
+	routeLimiters             map[string]*rate.Limiter
+	cors                      *restful.CrossOriginResourceSharing
+	disabledRoutes            map[string]bool
+}
+
+func newRuntimeOptions(config *Config) *runtimeOptions {
+	opts := &runtimeOptions{
+		slowQueryThresholdInMilli: config.SlowQueryThresholdInMilli,
+		debug:                     config.Debug,
+		accessLogSampling:         config.AccessLogSampling,
+		limiter:                   newRateLimiter(config.RateLimit.QPS, config.RateLimit.Burst),
+		routeLimiters:             make(map[string]*rate.Limiter),
+		disabledRoutes:            make(map[string]bool),
+	}
+	for _, route := range config.RateLimit.Routes {
+		if l := newRateLimiter(route.QPS, route.Burst); l != nil {
+			opts.routeLimiters[routeKey(route.Method, route.Path)] = l
+		}
+	}
+	if config.CORS.Enable {
+		opts.cors = &restful.CrossOriginResourceSharing{
+			AllowedDomains: config.CORS.AllowOrigins,
+			AllowedMethods: config.CORS.AllowMethods,
+			AllowedHeaders: config.CORS.AllowHeaders,
+			ExposeHeaders:  config.CORS.ExposeHeaders,
+			CookiesAllowed: config.CORS.AllowCredentials,
+			MaxAge:         config.CORS.MaxAge,
+			Container:      config.container,
+		}
+	}
+	for _, route := range config.DisabledRoutes {
+		opts.disabledRoutes[strings.TrimSpace(route)] = true
+	}
+	return opts
+}
+
+func newRateLimiter(qps float64, burst int) *rate.Limiter {
+	if qps <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = int(qps)
+		if burst < 1 {
+			burst = 1
+		}
+	}
+	return rate.NewLimiter(rate.Limit(qps), burst)
+}
+
+func routeKey(method, path string) string {
+	if method == "" {
+		return path
+	}
+	return strings.ToUpper(method) + " " + path
+}
+
+// routeLimiter returns the limiter of "METHOD path", then of "path"
+func (opts *runtimeOptions) routeLimiter(method, path string) *rate.Limiter {
+	if l, ok := opts.routeLimiters[routeKey(method, path)]; ok {
+		return l
+	}
+	return opts.routeLimiters[path]
+}
+
+func (opts *runtimeOptions) routeDisabled(method, path string) bool {
+	return opts.disabledRoutes[routeKey(method, path)] || opts.disabledRoutes[path]
+}
+
+// sampled reports whether a normal access log should be written
+func (opts *runtimeOptions) sampled() bool {
+	if opts.debug || opts.accessLogSampling >= 1 {
+		return true
+	}
+	return rand.Float64() < opts.accessLogSampling
+}
+
+func (config *Config) options() *runtimeOptions {
+	if opts, ok := config.runtime.Load().(*runtimeOptions); ok {
+		return opts
+	}
+	opts := newRuntimeOptions(config)
+	config.runtime.Store(opts)
+	return opts
+}
+
+// watch applies the runtime fields under key whenever the config source changes
+func (config *Config) watch(key string) {
+	conf.OnChange(func(c *conf.Configuration) {
+		config.reload(c, key)
+	})
+}
+
+func (config *Config) reload(c *conf.Configuration, key string) {
+	next := DefaultConfig()
+	if err := c.UnmarshalKey(key, next); err != nil {
+		config.logger.Error("reload config", xlog.FieldErr(err), xlog.FieldKey(key))
+		return
+	}
+	next.container = config.container
+
+	config.mu.Lock()
+	changed, serviceChanged := false, false
+	for _, name := range append(dynamicFields, serviceFields...) {
+		prev := reflect.ValueOf(config).Elem().FieldByName(name)
+		value := reflect.ValueOf(next).Elem().FieldByName(name)
+		if reflect.DeepEqual(prev.Interface(), value.Interface()) {
+			continue
+		}
+		config.logger.Info("config changed", xlog.FieldKey(key), xlog.FieldName(name),
+			zap.Any("old", prev.Interface()), zap.Any("new", value.Interface()))
+		prev.Set(value)
+		changed = true
+		serviceChanged = serviceChanged || isServiceField(name)
+	}
+	if changed {
+		config.runtime.Store(newRuntimeOptions(config))
+	}
+	config.mu.Unlock()
+	if serviceChanged {
+		config.register()
+	}
+}
+
+// dynamicFields are the Config fields applied without restart
+var dynamicFields = []string{
+	"SlowQueryThresholdInMilli",
+	"Debug",
+	"AccessLogSampling",
+	"RateLimit",
+	"CORS",
+	"DisabledRoutes",
+}
+
+// serviceFields are the Config fields of the registry service info
+var serviceFields = []string{
+	"Weight",
+	"Enable",
+	"Region",
+	"Zone",
+	"Version",
+	"Labels",
+}
+
+func isServiceField(name string) bool {
+	for _, field := range serviceFields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+func corsMiddleware(config *Config) restful.FilterFunction {
+	return func(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+		if cors := config.options().cors; cors != nil {
+			cors.Filter(req, resp, chain)
+			return
+		}
+		chain.ProcessFilter(req, resp)
+	}
+}
+
+// disabledRouteMiddleware answers 503 for routes listed in DisabledRoutes
+func disabledRouteMiddleware(config *Config) restful.FilterFunction {
+	return func(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+		if config.options().routeDisabled(req.Request.Method, req.SelectedRoutePath()) {
+			WriteError(req, resp, errcode.New(codeRouteDisabled))
+			return
+		}
+		chain.ProcessFilter(req, resp)
+	}
+}
+
+// rateLimitMiddleware answers 429 for requests over the server or route rate limit
+func rateLimitMiddleware(config *Config) restful.FilterFunction {
+	return func(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+		opts := config.options()
+		if !allow(opts.limiter) || !allow(opts.routeLimiter(req.Request.Method, req.SelectedRoutePath())) {
+			resp.AddHeader("Retry-After", "1")
+			WriteError(req, resp, errcode.New(codeRateLimited))
+			return
+		}
+		chain.ProcessFilter(req, resp)
+	}
+}
+
+func allow(l *rate.Limiter) bool {
+	return l == nil || l.Allow()
+}
@@ -0,0 +1,59 @@
+package xrestful
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/douyu/jupiter/pkg/conf"
+	restful "github.com/emicklei/go-restful/v3"
+)
+
+func TestConfigReload(t *testing.T) {
+	config := DefaultConfig().WithHost("127.0.0.1").WithPort(0).WithContainer(restful.NewContainer())
+	config.DisableTrace = true
+	s := config.Build()
+	defer s.Stop()
+	ws := new(restful.WebService)
+	ws.Route(ws.GET("/users/{id}").To(func(req *restful.Request, resp *restful.Response) {
+		resp.WriteErrorString(http.StatusOK, req.PathParameter("id"))
+	}))
+	s.Add(ws)
+
+	get := func() int {
+		rec := httptest.NewRecorder()
+		s.Container().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/1", nil))
+		return rec.Code
+	}
+	if code := get(); code != http.StatusOK {
+		t.Fatalf("code = %d", code)
+	}
+
+	c := conf.New()
+	err := c.LoadFromReader(strings.NewReader(`{"server": {"disabledRoutes": ["GET /users/{id}"], "slowQueryThresholdInMilli": 100}}`), json.Unmarshal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.reload(c, "server")
+	if code := get(); code != http.StatusServiceUnavailable {
+		t.Fatalf("disabled code = %d", code)
+	}
+	if config.options().slowQueryThresholdInMilli != 100 {
+		t.Fatalf("slow = %d", config.options().slowQueryThresholdInMilli)
+	}
+
+	c = conf.New()
+	err = c.LoadFromReader(strings.NewReader(`{"server": {"rateLimit": {"routes": [{"path": "/users/{id}", "qps": 1, "burst": 1}]}}}`), json.Unmarshal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.reload(c, "server")
+	if code := get(); code != http.StatusOK {
+		t.Fatalf("first code = %d", code)
+	}
+	if code := get(); code != http.StatusTooManyRequests {
+		t.Fatalf("limited code = %d", code)
+	}
+}
@@ -0,0 +1,154 @@
+// Package errcode is the business error code catalog. A Code binds a number
+// to an HTTP status, a gRPC code and message templates per language; handlers
+// raise it with New and the server renders the message in the language asked
+// by Accept-Language.
+package errcode
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultLanguage is used when no message matches the requested languages
+var DefaultLanguage = "en"
+
+// Code is one entry of the catalog
+type Code struct {
+	Code int `json:"code"`
+	// HTTP 状态码, 默认500
+	Status int `json:"status"`
+	// gRPC 状态码, 默认Unknown
+	GRPCCode codes.Code `json:"grpcCode"`
+	// 各语言的消息模板, 如 {"zh-CN": "用户%d不存在", "en": "user %d not found"},
+	// 模板使用fmt格式, 参数顺序不同时使用 %[2]v
+	Messages map[string]string `json:"messages"`
+}
+
+// Message returns the template of the best language in accept, an
+// Accept-Language header value or a single language tag
+func (c *Code) Message(accept string) string {
+	if len(c.Messages) == 0 {
+		return http.StatusText(c.Status)
+	}
+	return c.Messages[c.Language(accept)]
+}
+
+// Language returns the language of the message picked for accept, empty when c has no message
+func (c *Code) Language(accept string) string {
+	return MatchLanguage(accept, c.languages())
+}
+
+func (c *Code) languages() []string {
+	langs := make([]string, 0, len(c.Messages))
+	for lang := range c.Messages {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+var catalog = struct {
+	sync.RWMutex
+	codes map[int]*Code
+}{codes: make(map[int]*Code)}
+
+// Register adds c to the catalog, it panics when the code is already registered
+func Register(c Code) *Code {
+	if c.Status == 0 {
+		c.Status = http.StatusInternalServerError
+	}
+	if c.GRPCCode == codes.OK {
+		c.GRPCCode = codes.Unknown
+	}
+	catalog.Lock()
+	defer catalog.Unlock()
+	if _, ok := catalog.codes[c.Code]; ok {
+		panic(fmt.Sprintf("errcode: code %d already registered", c.Code))
+	}
+	catalog.codes[c.Code] = &c
+	return &c
+}
+
+// Lookup returns the registered code
+func Lookup(code int) (*Code, bool) {
+	catalog.RLock()
+	defer catalog.RUnlock()
+	c, ok := catalog.codes[code]
+	return c, ok
+}
+
+// Codes returns the catalog ordered by code
+func Codes() []*Code {
+	catalog.RLock()
+	list := make([]*Code, 0, len(catalog.codes))
+	for _, c := range catalog.codes {
+		list = append(list, c)
+	}
+	catalog.RUnlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].Code < list[j].Code })
+	return list
+}
+
+// Error is a raised Code with the arguments of its message template
+type Error struct {
+	*Code
+	Args []interface{}
+	// 随错误一起返回的详情, 如各字段的校验错误
+	Details interface{}
+}
+
+// WithDetails sets the details rendered with e
+func (e *Error) WithDetails(details interface{}) *Error {
+	e.Details = details
+	return e
+}
+
+// New raises code with the template arguments args, an unregistered code
+// is raised as an internal error
+func New(code int, args ...interface{}) *Error {
+	c, ok := Lookup(code)
+	if !ok {
+		c = &Code{Code: code, Status: http.StatusInternalServerError, GRPCCode: codes.Unknown}
+	}
+	return &Error{Code: c, Args: args}
+}
+
+// Message formats the message in the best language of accept
+func (e *Error) Message(accept string) string {
+	tmpl := e.Code.Message(accept)
+	if len(e.Args) == 0 {
+		return tmpl
+	}
+	return fmt.Sprintf(tmpl, e.Args...)
+}
+
+// Error returns the message in DefaultLanguage
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d:%s", e.Code.Code, e.Message(DefaultLanguage))
+}
+
+// Is reports whether target is an Error of the same code
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code.Code == e.Code.Code
+}
+
+// GRPCStatus converts e for grpc, the message is "code:msg" as parsed by the xrestful grpc proxy
+func (e *Error) GRPCStatus() *status.Status {
+	return status.New(e.GRPCCode, e.Error())
+}
+
+// FromError returns the Error in the chain of err
+func FromError(err error) (*Error, bool) {
+	var e *Error
+	if errors.As(err, &e) {
+		return e, true
+	}
+	return nil, false
+}
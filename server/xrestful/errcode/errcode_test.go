@@ -0,0 +1,52 @@
+package errcode
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestError(t *testing.T) {
+	Register(Code{
+		Code:     20001,
+		Status:   http.StatusNotFound,
+		GRPCCode: codes.NotFound,
+		Messages: map[string]string{"zh-CN": "用户%d不存在", "en": "user %d not found"},
+	})
+	err := fmt.Errorf("get user: %w", New(20001, 7))
+
+	e, ok := FromError(err)
+	if !ok || e.Status != http.StatusNotFound {
+		t.Fatalf("FromError = %v %v", e, ok)
+	}
+	if !errors.Is(err, New(20001)) || errors.Is(err, New(20002)) {
+		t.Error("errors.Is by code")
+	}
+	cases := map[string]string{
+		"":                        "user 7 not found",
+		"zh-CN,zh;q=0.9,en;q=0.8": "用户7不存在",
+		"zh-TW":                   "用户7不存在",
+		"fr;q=0.9, en-US;q=0.8":   "user 7 not found",
+		"zh;q=0, en-GB":           "user 7 not found",
+		"de":                      "user 7 not found",
+	}
+	for accept, want := range cases {
+		if got := e.Message(accept); got != want {
+			t.Errorf("Message(%q) = %q, want %q", accept, got, want)
+		}
+	}
+	if s := status.Convert(e); s.Code() != codes.NotFound || s.Message() != "20001:user 7 not found" {
+		t.Errorf("grpc status = %v", s)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("duplicate code registered")
+		}
+	}()
+	Register(Code{Code: 20001})
+}
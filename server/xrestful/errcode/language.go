@@ -0,0 +1,86 @@
+package errcode
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type weightedLanguage struct {
+	tag string
+	q   float64
+}
+
+// MatchLanguage picks the best of available for an Accept-Language value.
+// A tag matches exactly, then by its primary subtag, so zh matches zh-CN and
+// zh-TW falls back to zh-CN. DefaultLanguage is used when nothing matches,
+// then the first of available.
+func MatchLanguage(accept string, available []string) string {
+	if len(available) == 0 {
+		return ""
+	}
+	for _, want := range parseAcceptLanguage(accept) {
+		if want == "*" {
+			break
+		}
+		if lang, ok := lookupLanguage(want, available); ok {
+			return lang
+		}
+	}
+	if lang, ok := lookupLanguage(DefaultLanguage, available); ok {
+		return lang
+	}
+	return available[0]
+}
+
+func lookupLanguage(want string, available []string) (string, bool) {
+	for _, lang := range available {
+		if strings.EqualFold(lang, want) {
+			return lang, true
+		}
+	}
+	base := primarySubtag(want)
+	for _, lang := range available {
+		if strings.EqualFold(primarySubtag(lang), base) {
+			return lang, true
+		}
+	}
+	return "", false
+}
+
+func primarySubtag(tag string) string {
+	if i := strings.IndexAny(tag, "-_"); i > 0 {
+		return tag[:i]
+	}
+	return tag
+}
+
+// parseAcceptLanguage returns the tags of header by descending q, zero q excluded
+func parseAcceptLanguage(header string) []string {
+	var langs []weightedLanguage
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			langs = append(langs, weightedLanguage{tag: tag, q: q})
+		}
+	}
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+	tags := make([]string, len(langs))
+	for i, l := range langs {
+		tags[i] = l.tag
+	}
+	return tags
+}
@@ -1,20 +1,52 @@
 package xrestful
 
 import (
+	"net/http"
+
+	"github.com/system18188/jupiter-plugin/server/xrestful/errcode"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
 )
 
 var (
-	errBadRequest         = status.Errorf(codes.InvalidArgument, createStatusErr(codeMSInvalidParam, "bad request"))
-	errMicroDefault       = status.Errorf(codes.Internal, createStatusErr(codeMS, "micro default"))
-	errMicroInvoke        = status.Errorf(codes.Internal, createStatusErr(codeMSInvoke, "invoke failed"))
-	errMicroInvokeLen     = status.Errorf(codes.Internal, createStatusErr(codeMSInvokeLen, "invoke result not 2 item"))
-	errMicroInvokeInvalid = status.Errorf(codes.Internal, createStatusErr(codeMSSecondItemNotError, "second invoke res not a error"))
-	errMicroResInvalid    = status.Errorf(codes.Internal, createStatusErr(codeMSResErr, "response is not valid"))
+	errBadRequest         = registerCode(codeMSInvalidParam, codes.InvalidArgument, StatusBadRequest, "请求参数错误", "bad request")
+	errMicroDefault       = registerCode(codeMS, codes.Internal, StatusInternalServerError, "服务内部错误", "micro default")
+	errMicroInvoke        = registerCode(codeMSInvoke, codes.Internal, StatusInternalServerError, "调用失败", "invoke failed")
+	errMicroInvokeLen     = registerCode(codeMSInvokeLen, codes.Internal, StatusInternalServerError, "调用结果不是2项", "invoke result not 2 item")
+	errMicroInvokeInvalid = registerCode(codeMSSecondItemNotError, codes.Internal, StatusInternalServerError, "调用结果第2项不是error", "second invoke res not a error")
+	errMicroResInvalid    = registerCode(codeMSResErr, codes.Internal, StatusInternalServerError, "响应无效", "response is not valid")
 )
 
+// codes of the filters, written with WriteError in the language of the request
+var _ = []*errcode.Code{
+	register(codeIdempotencyKeyMissing, codes.InvalidArgument, StatusBadRequest, "缺少"+HeaderIdempotencyKey+"请求头", "missing "+HeaderIdempotencyKey+" header"),
+	register(codeIdempotencyInProgress, codes.Aborted, StatusConflict, "相同"+HeaderIdempotencyKey+"的请求正在处理", "request with the same "+HeaderIdempotencyKey+" is in progress"),
+	register(codeIdempotencyMismatch, codes.InvalidArgument, http.StatusUnprocessableEntity, HeaderIdempotencyKey+"已用于不同的请求", HeaderIdempotencyKey+" reused with a different request"),
+	register(codeBodyTooLarge, codes.ResourceExhausted, StatusRequestEntityTooLarge, "请求体过大", "request body too large"),
+	register(codeBodyUnreadable, codes.InvalidArgument, StatusBadRequest, "读取请求体失败", "read request body failed"),
+	register(codeIPForbidden, codes.PermissionDenied, StatusForbidden, "禁止访问", "forbidden"),
+	register(codeOverloaded, codes.Unavailable, StatusServiceUnavailable, "服务繁忙, 请稍后重试", "service unavailable"),
+	register(codeRouteDisabled, codes.Unavailable, StatusServiceUnavailable, "接口已停用", "route disabled"),
+	register(codeRateLimited, codes.ResourceExhausted, StatusTooManyRequests, "请求过于频繁", "too many requests"),
+	register(codeVersionInvalid, codes.InvalidArgument, StatusBadRequest, "无效的"+HeaderAcceptVersion+"请求头%q", "invalid "+HeaderAcceptVersion+" header %q"),
+	register(codeVersionUnsupported, codes.InvalidArgument, StatusBadRequest, "不支持的接口版本%d", "unsupported api version %d"),
+	register(codeOpenAPIInvalid, codes.InvalidArgument, StatusBadRequest, "请求与接口文档不符", "request does not match the api document"),
+}
+
+func register(code int, grpcCode codes.Code, status int, zh, en string) *errcode.Code {
+	return errcode.Register(errcode.Code{
+		Code:     code,
+		Status:   status,
+		GRPCCode: grpcCode,
+		Messages: map[string]string{"zh-CN": zh, "en": en},
+	})
+}
+
+// registerCode adds a code of the grpc proxy to the catalog and returns its grpc error
+func registerCode(code int, grpcCode codes.Code, status int, zh, en string) error {
+	return (&errcode.Error{Code: register(code, grpcCode, status, zh, en)}).GRPCStatus().Err()
+}
+
 // HTTPError wraps handler error.
 type HTTPError struct {
 	Code    int
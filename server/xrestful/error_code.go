@@ -0,0 +1,80 @@
+package xrestful
+
+import (
+	"net/http"
+
+	restful "github.com/emicklei/go-restful/v3"
+	"github.com/system18188/jupiter-plugin/server/xrestful/errcode"
+)
+
+// HeaderAcceptLanguage ...
+const HeaderAcceptLanguage = "Accept-Language"
+
+// ErrorBody is the entity written by WriteError
+type ErrorBody struct {
+	Code    int    `json:"code" xml:"code" yaml:"code"`
+	Message string `json:"message" xml:"message" yaml:"message"`
+	// errcode.Error 的 Details
+	Details interface{} `json:"details,omitempty" xml:"details,omitempty" yaml:"details,omitempty"`
+}
+
+// WriteError renders err in the negotiated format with the message in the language
+// of the Accept-Language header. An errcode.Error is written with the status of its
+// code, an HTTPError with its own code, any other error as the 1000 internal error.
+func WriteError(req *restful.Request, resp *restful.Response, err error) error {
+	if he, ok := err.(*HTTPError); ok {
+		return Render(resp, he.Code, &ErrorBody{Code: he.Code, Message: he.Message})
+	}
+	if he, ok := err.(HTTPError); ok {
+		return Render(resp, he.Code, &ErrorBody{Code: he.Code, Message: he.Message})
+	}
+	e, ok := errcode.FromError(err)
+	if !ok {
+		e = errcode.New(codeMS)
+	}
+	accept := req.HeaderParameter(HeaderAcceptLanguage)
+	if lang := e.Language(accept); lang != "" {
+		resp.AddHeader("Content-Language", lang)
+	}
+	return Render(resp, e.Status, &ErrorBody{Code: e.Code.Code, Message: e.Message(accept), Details: e.Details})
+}
+
+// ErrorCodeInfo is one entry of the error code catalog route
+type ErrorCodeInfo struct {
+	Code     int    `json:"code" xml:"code" yaml:"code"`
+	Status   int    `json:"status" xml:"status" yaml:"status"`
+	GRPCCode string `json:"grpcCode" xml:"grpcCode" yaml:"grpcCode"`
+	// 请求指定lang时只返回该语言的消息
+	Message  string            `json:"message,omitempty" xml:"message,omitempty" yaml:"message,omitempty"`
+	Messages map[string]string `json:"messages,omitempty" xml:"-" yaml:"messages,omitempty"`
+}
+
+// ErrorCodeService lists the error code catalog under GET path for front-end teams,
+// the lang query parameter picks one language, e.g.
+//
+//	server.Add(xrestful.ErrorCodeService("/error-codes"))
+func ErrorCodeService(path string) *restful.WebService {
+	ws := new(restful.WebService)
+	ws.Path(path).Produces(RendererMIMEs()...)
+	ws.Route(ws.GET("").To(listErrorCodes).
+		Doc("list the business error codes").
+		Param(ws.QueryParameter("lang", "message language, such as zh-CN or en")).
+		Returns(http.StatusOK, StatusText(http.StatusOK), []ErrorCodeInfo{}))
+	return ws
+}
+
+func listErrorCodes(req *restful.Request, resp *restful.Response) {
+	lang := req.QueryParameter("lang")
+	codes := errcode.Codes()
+	list := make([]ErrorCodeInfo, 0, len(codes))
+	for _, c := range codes {
+		info := ErrorCodeInfo{Code: c.Code, Status: c.Status, GRPCCode: c.GRPCCode.String()}
+		if lang != "" {
+			info.Message = c.Message(lang)
+		} else {
+			info.Messages = c.Messages
+		}
+		list = append(list, info)
+	}
+	Render(resp, http.StatusOK, list)
+}
@@ -0,0 +1,60 @@
+package xrestful
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	restful "github.com/emicklei/go-restful/v3"
+	"github.com/system18188/jupiter-plugin/server/xrestful/errcode"
+	"google.golang.org/grpc/codes"
+)
+
+func TestWriteError(t *testing.T) {
+	errcode.Register(errcode.Code{
+		Code:     30001,
+		Status:   http.StatusConflict,
+		GRPCCode: codes.AlreadyExists,
+		Messages: map[string]string{"zh-CN": "名称%q已存在", "en": "name %q exists"},
+	})
+	container := restful.NewContainer()
+	container.Filter(negotiateMiddleware())
+	ws := new(restful.WebService)
+	ws.Route(ws.GET("/users").To(func(req *restful.Request, resp *restful.Response) {
+		WriteError(req, resp, errcode.New(30001, "bob"))
+	}))
+	container.Add(ws)
+	container.Add(ErrorCodeService("/error-codes"))
+
+	r := httptest.NewRequest(http.MethodGet, "/users", nil)
+	r.Header.Set(HeaderAcceptLanguage, "zh-CN,en;q=0.5")
+	rec := httptest.NewRecorder()
+	container.ServeHTTP(rec, r)
+	var body ErrorBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusConflict || body.Code != 30001 || body.Message != `名称"bob"已存在` {
+		t.Fatalf("%d %+v", rec.Code, body)
+	}
+	if lang := rec.Header().Get("Content-Language"); lang != "zh-CN" {
+		t.Errorf("Content-Language = %q", lang)
+	}
+
+	rec = httptest.NewRecorder()
+	container.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/error-codes?lang=en", nil))
+	var list []ErrorCodeInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, info := range list {
+		if info.Code == 30001 {
+			found = info.Status == http.StatusConflict && info.GRPCCode == "AlreadyExists" && info.Message == "name %q exists"
+		}
+	}
+	if !found {
+		t.Errorf("catalog = %+v", list)
+	}
+}
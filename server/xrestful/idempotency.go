@@ -0,0 +1,235 @@
+package xrestful
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/douyu/jupiter/pkg/xlog"
+	restful "github.com/emicklei/go-restful/v3"
+	"github.com/system18188/jupiter-plugin/server/xrestful/errcode"
+)
+
+const (
+	// HeaderIdempotencyKey ...
+	HeaderIdempotencyKey = "Idempotency-Key"
+	// HeaderIdempotentReplayed 标记响应来自幂等记录的重放
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+)
+
+// IdempotencyRecord is the first response recorded for an Idempotency-Key
+type IdempotencyRecord struct {
+	Key         string `json:"key"`
+	Fingerprint string `json:"fingerprint"`
+	// Done is false while the original request is still running
+	Done      bool        `json:"done"`
+	Status    int         `json:"status"`
+	Header    http.Header `json:"header"`
+	Body      []byte      `json:"body"`
+	CreatedAt time.Time   `json:"createdAt"`
+}
+
+// IdempotencyStore stores idempotency records, see NewMemoryIdempotencyStore and NewRedisIdempotencyStore
+type IdempotencyStore interface {
+	// Acquire atomically saves record if key does not exist yet.
+	// When the key exists the stored record is returned and acquired is false.
+	Acquire(key string, record *IdempotencyRecord, ttl time.Duration) (existing *IdempotencyRecord, acquired bool, err error)
+	// Complete saves the finished record of an acquired key
+	Complete(key string, record *IdempotencyRecord, ttl time.Duration) error
+	// Release removes an acquired key so that the request can be retried
+	Release(key string) error
+}
+
+// IdempotencyConfig Idempotency-Key filter options
+type IdempotencyConfig struct {
+	// 需要幂等处理的方法, 默认 POST PATCH
+	Methods []string
+	// 记录保存时间, 默认24小时
+	TTL time.Duration
+	// 请求必须携带Idempotency-Key
+	Required bool
+	// key的作用域, 默认按SetPrincipal设置的操作者隔离. 为nil时不隔离,
+	// 使用相同key和请求的其他调用方会收到第一个请求保存的响应, 包括响应头
+	ScopeFunc func(req *restful.Request) string
+	// 幂等记录存储, 默认为内存存储
+	Store IdempotencyStore
+	// 计算请求指纹时读取的请求体上限, 超过时返回413, 默认1MB
+	MaxBodySize int64
+
+	logger *xlog.Logger
+}
+
+// DefaultIdempotencyConfig ...
+func DefaultIdempotencyConfig() *IdempotencyConfig {
+	return &IdempotencyConfig{
+		Methods:     []string{http.MethodPost, http.MethodPatch},
+		TTL:         24 * time.Hour,
+		MaxBodySize: 1 << 20,
+		ScopeFunc:   Principal,
+		logger:      xlog.JupiterLogger.With(xlog.FieldMod(ModName)),
+	}
+}
+
+// WithStore ...
+func (config *IdempotencyConfig) WithStore(store IdempotencyStore) *IdempotencyConfig {
+	config.Store = store
+	return config
+}
+
+// WithTTL ...
+func (config *IdempotencyConfig) WithTTL(ttl time.Duration) *IdempotencyConfig {
+	config.TTL = ttl
+	return config
+}
+
+// WithScopeFunc ...
+func (config *IdempotencyConfig) WithScopeFunc(fn func(req *restful.Request) string) *IdempotencyConfig {
+	config.ScopeFunc = fn
+	return config
+}
+
+// WithLogger ...
+func (config *IdempotencyConfig) WithLogger(logger *xlog.Logger) *IdempotencyConfig {
+	config.logger = logger
+	return config
+}
+
+// Build create the Idempotency-Key filter, it can be used on a container, a WebService or a route
+func (config *IdempotencyConfig) Build() restful.FilterFunction {
+	if config.Store == nil {
+		config.Store = NewMemoryIdempotencyStore()
+	}
+	if config.logger == nil {
+		config.logger = xlog.JupiterLogger.With(xlog.FieldMod(ModName))
+	}
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = 1 << 20
+	}
+	return config.filter
+}
+
+func (config *IdempotencyConfig) filter(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+	if !containsMethod(config.Methods, req.Request.Method) {
+		chain.ProcessFilter(req, resp)
+		return
+	}
+	key := req.Request.Header.Get(HeaderIdempotencyKey)
+	if key == "" {
+		if config.Required {
+			WriteError(req, resp, errcode.New(codeIdempotencyKeyMissing))
+			return
+		}
+		chain.ProcessFilter(req, resp)
+		return
+	}
+	if config.ScopeFunc != nil {
+		key = config.ScopeFunc(req) + ":" + key
+	}
+
+	fingerprint, err := requestFingerprint(req.Request, config.MaxBodySize)
+	if err == errBodyTooLarge {
+		WriteError(req, resp, errcode.New(codeBodyTooLarge))
+		return
+	}
+	if err != nil {
+		WriteError(req, resp, errcode.New(codeBodyUnreadable))
+		return
+	}
+	record := &IdempotencyRecord{Key: key, Fingerprint: fingerprint, CreatedAt: time.Now()}
+	existing, acquired, err := config.Store.Acquire(key, record, config.TTL)
+	if err != nil {
+		config.logger.Error("idempotency acquire", xlog.FieldErr(err), xlog.FieldKey(key))
+		WriteError(req, resp, errcode.New(codeMS))
+		return
+	}
+	if !acquired {
+		switch {
+		case existing == nil:
+			WriteError(req, resp, errcode.New(codeIdempotencyInProgress))
+		case existing.Fingerprint != fingerprint:
+			WriteError(req, resp, errcode.New(codeIdempotencyMismatch))
+		case !existing.Done:
+			WriteError(req, resp, errcode.New(codeIdempotencyInProgress))
+		default:
+			header := resp.Header()
+			copyHeader(header, existing.Header)
+			header.Set(HeaderIdempotentReplayed, "true")
+			resp.WriteHeader(existing.Status)
+			resp.Write(existing.Body)
+		}
+		return
+	}
+
+	released := false
+	defer func() {
+		// handler panic 时释放key, 允许客户端重试
+		if !released {
+			config.release(key)
+		}
+	}()
+	rec := recordResponse(resp, func() {
+		chain.ProcessFilter(req, resp)
+	})
+	if rec.StatusCode() >= http.StatusInternalServerError {
+		config.release(key)
+	} else {
+		record.Done = true
+		record.Status = rec.StatusCode()
+		record.Header = rec.Header()
+		record.Body = rec.body.Bytes()
+		if err := config.Store.Complete(key, record, config.TTL); err != nil {
+			// 未保存的key一直处于进行中, 释放后客户端可以重试
+			config.logger.Error("idempotency complete", xlog.FieldErr(err), xlog.FieldKey(key))
+			config.release(key)
+		}
+	}
+	released = true
+	rec.flushTo(resp.ResponseWriter)
+}
+
+func (config *IdempotencyConfig) release(key string) {
+	if err := config.Store.Release(key); err != nil {
+		config.logger.Error("idempotency release", xlog.FieldErr(err), xlog.FieldKey(key))
+	}
+}
+
+var errBodyTooLarge = errors.New("request body too large for " + HeaderIdempotencyKey)
+
+// requestFingerprint hashes method, path with the query and body, the body is restored for the handler.
+// Bodies over max are not read and return errBodyTooLarge
+func requestFingerprint(r *http.Request, max int64) (string, error) {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+	if r.Body != nil && r.Body != http.NoBody {
+		if r.ContentLength > max {
+			return "", errBodyTooLarge
+		}
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, max+1))
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		if err != nil {
+			return "", err
+		}
+		if int64(len(body)) > max {
+			return "", errBodyTooLarge
+		}
+		h.Write(body)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func containsMethod(methods []string, method string) bool {
+	for _, m := range methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
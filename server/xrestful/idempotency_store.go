@@ -0,0 +1,142 @@
+package xrestful
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/douyu/jupiter/pkg/client/redis"
+	goredis "github.com/go-redis/redis"
+)
+
+// MemoryIdempotencyStore keeps idempotency records in process memory
+type MemoryIdempotencyStore struct {
+	mu    sync.Mutex
+	items map[string]memoryIdempotencyItem
+	// 上次清理过期记录的时间
+	swept time.Time
+}
+
+// memorySweepInterval 清理过期记录的间隔
+const memorySweepInterval = time.Minute
+
+type memoryIdempotencyItem struct {
+	record     IdempotencyRecord
+	expiration time.Time
+}
+
+// NewMemoryIdempotencyStore ...
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		items: make(map[string]memoryIdempotencyItem),
+	}
+}
+
+// Acquire implements IdempotencyStore
+func (m *MemoryIdempotencyStore) Acquire(key string, record *IdempotencyRecord, ttl time.Duration) (*IdempotencyRecord, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	m.sweep(now)
+	if item, ok := m.items[key]; ok && now.Before(item.expiration) {
+		existing := item.record
+		return &existing, false, nil
+	}
+	m.items[key] = memoryIdempotencyItem{record: *record, expiration: now.Add(ttl)}
+	return nil, true, nil
+}
+
+// sweep deletes the expired records at most once per memorySweepInterval
+func (m *MemoryIdempotencyStore) sweep(now time.Time) {
+	if now.Sub(m.swept) < memorySweepInterval {
+		return
+	}
+	m.swept = now
+	for key, item := range m.items {
+		if !now.Before(item.expiration) {
+			delete(m.items, key)
+		}
+	}
+}
+
+// Len returns the number of records, expired records may be counted until they are swept
+func (m *MemoryIdempotencyStore) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.items)
+}
+
+// Complete implements IdempotencyStore
+func (m *MemoryIdempotencyStore) Complete(key string, record *IdempotencyRecord, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.items[key] = memoryIdempotencyItem{record: *record, expiration: time.Now().Add(ttl)}
+	return nil
+}
+
+// Release implements IdempotencyStore
+func (m *MemoryIdempotencyStore) Release(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.items, key)
+	return nil
+}
+
+// RedisIdempotencyStore shares idempotency records between instances through redis
+type RedisIdempotencyStore struct {
+	redis  *redis.Redis
+	prefix string
+}
+
+// NewRedisIdempotencyStore returns a RedisIdempotencyStore with the "xrestful:idempotency:" key prefix
+func NewRedisIdempotencyStore(redis *redis.Redis) *RedisIdempotencyStore {
+	return NewRedisIdempotencyStoreWithPrefix(redis, "xrestful:idempotency:")
+}
+
+// NewRedisIdempotencyStoreWithPrefix returns a RedisIdempotencyStore, prefix avoids naming clashes
+func NewRedisIdempotencyStoreWithPrefix(redis *redis.Redis, prefix string) *RedisIdempotencyStore {
+	return &RedisIdempotencyStore{
+		redis:  redis,
+		prefix: prefix,
+	}
+}
+
+// Acquire implements IdempotencyStore with SETNX
+func (r *RedisIdempotencyStore) Acquire(key string, record *IdempotencyRecord, ttl time.Duration) (*IdempotencyRecord, bool, error) {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return nil, false, err
+	}
+	ok, err := r.redis.SetNxWithErr(r.prefix+key, b, ttl)
+	if err != nil || ok {
+		return nil, ok, err
+	}
+	b, err = r.redis.GetRaw(r.prefix + key)
+	if err == goredis.Nil {
+		// 记录刚好过期或被释放
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var existing IdempotencyRecord
+	if err := json.Unmarshal(b, &existing); err != nil {
+		return nil, false, err
+	}
+	return &existing, false, nil
+}
+
+// Complete implements IdempotencyStore
+func (r *RedisIdempotencyStore) Complete(key string, record *IdempotencyRecord, ttl time.Duration) error {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return r.redis.SetWithErr(r.prefix+key, b, ttl)
+}
+
+// Release implements IdempotencyStore
+func (r *RedisIdempotencyStore) Release(key string) error {
+	_, err := r.redis.DelWithErr(r.prefix + key)
+	return err
+}
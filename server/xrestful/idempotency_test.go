@@ -0,0 +1,158 @@
+package xrestful
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	restful "github.com/emicklei/go-restful/v3"
+)
+
+func TestIdempotencyFilter(t *testing.T) {
+	var calls int
+	store := NewMemoryIdempotencyStore()
+	ws := new(restful.WebService)
+	ws.Filter(DefaultIdempotencyConfig().WithStore(store).Build())
+	ws.Route(ws.POST("/orders").To(func(req *restful.Request, resp *restful.Response) {
+		calls++
+		resp.WriteErrorString(http.StatusCreated, "order")
+	}))
+	container := restful.NewContainer()
+	container.Add(ws)
+
+	post := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+		req.Header.Set(HeaderIdempotencyKey, key)
+		rec := httptest.NewRecorder()
+		container.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := post("k1", "a"); rec.Code != http.StatusCreated || rec.Body.String() != "order" {
+		t.Fatalf("first: code=%d body=%q", rec.Code, rec.Body.String())
+	}
+	rec := post("k1", "a")
+	if rec.Code != http.StatusCreated || rec.Header().Get(HeaderIdempotentReplayed) != "true" || calls != 1 {
+		t.Fatalf("replay: code=%d calls=%d", rec.Code, calls)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader("b"))
+	req.Header.Set(HeaderIdempotencyKey, "k1")
+	req.Header.Set(HeaderAcceptLanguage, "zh-CN,en;q=0.5")
+	rec = httptest.NewRecorder()
+	container.ServeHTTP(rec, req)
+	var body ErrorBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("mismatch: %v %q", err, rec.Body.String())
+	}
+	if rec.Code != http.StatusUnprocessableEntity || body.Code != codeIdempotencyMismatch ||
+		body.Message != HeaderIdempotencyKey+"已用于不同的请求" || rec.Header().Get("Content-Language") != "zh-CN" {
+		t.Fatalf("mismatch: code=%d body=%+v", rec.Code, body)
+	}
+
+	fingerprint, _ := requestFingerprint(httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader("c")), 1<<20)
+	// 没有操作者时作用域为空
+	store.Acquire(":k2", &IdempotencyRecord{Key: ":k2", Fingerprint: fingerprint}, time.Minute)
+	if rec := post("k2", "c"); rec.Code != http.StatusConflict {
+		t.Fatalf("in progress: code=%d", rec.Code)
+	}
+}
+
+type failingCompleteStore struct {
+	*MemoryIdempotencyStore
+}
+
+func (s failingCompleteStore) Complete(key string, record *IdempotencyRecord, ttl time.Duration) error {
+	return errors.New("store down")
+}
+
+func TestIdempotencyFailures(t *testing.T) {
+	var calls int
+	config := DefaultIdempotencyConfig().WithStore(failingCompleteStore{NewMemoryIdempotencyStore()})
+	config.MaxBodySize = 8
+	ws := new(restful.WebService)
+	ws.Filter(config.Build())
+	ws.Route(ws.POST("/orders").To(func(req *restful.Request, resp *restful.Response) {
+		calls++
+		resp.WriteHeader(http.StatusCreated)
+	}))
+	container := restful.NewContainer()
+	container.Add(ws)
+
+	post := func(body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+		req.ContentLength = -1
+		req.Header.Set(HeaderIdempotencyKey, "k1")
+		rec := httptest.NewRecorder()
+		container.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	// 保存失败后key被释放, 重试不会得到409
+	for i := 0; i < 2; i++ {
+		if code := post("a"); code != http.StatusCreated {
+			t.Fatalf("attempt %d: code=%d", i, code)
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("calls=%d", calls)
+	}
+	if code := post(strings.Repeat("x", 9)); code != http.StatusRequestEntityTooLarge || calls != 2 {
+		t.Fatalf("large body: code=%d calls=%d", code, calls)
+	}
+}
+
+func TestMemoryIdempotencyStoreSweep(t *testing.T) {
+	store := NewMemoryIdempotencyStore()
+	for _, key := range []string{"a", "b", "c"} {
+		store.Acquire(key, &IdempotencyRecord{Key: key}, time.Nanosecond)
+	}
+	time.Sleep(time.Millisecond)
+	store.mu.Lock()
+	store.swept = time.Time{}
+	store.mu.Unlock()
+	if _, acquired, _ := store.Acquire("d", &IdempotencyRecord{Key: "d"}, time.Minute); !acquired {
+		t.Fatal("not acquired")
+	}
+	if n := store.Len(); n != 1 {
+		t.Fatalf("%d records after sweep", n)
+	}
+}
+
+func TestIdempotencyScope(t *testing.T) {
+	var calls int
+	container := restful.NewContainer()
+	container.Filter(func(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+		SetPrincipal(req, req.HeaderParameter("X-User"))
+		chain.ProcessFilter(req, resp)
+	})
+	ws := new(restful.WebService)
+	ws.Filter(DefaultIdempotencyConfig().Build())
+	ws.Route(ws.POST("/orders").To(func(req *restful.Request, resp *restful.Response) {
+		calls++
+		resp.WriteErrorString(http.StatusCreated, req.HeaderParameter("X-User"))
+	}))
+	container.Add(ws)
+
+	post := func(user, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader("a"))
+		req.Header.Set(HeaderIdempotencyKey, "k1")
+		req.Header.Set("X-User", user)
+		rec := httptest.NewRecorder()
+		container.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := post("alice", "/orders?coupon=A"); rec.Code != http.StatusCreated {
+		t.Fatalf("alice: %d", rec.Code)
+	}
+	// 同一用户的key用于不同的查询参数
+	if rec := post("alice", "/orders?coupon=B"); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("other query: %d", rec.Code)
+	}
+	// 其他用户使用相同的key和请求不会收到alice的响应
+	if rec := post("bob", "/orders?coupon=A"); rec.Code != http.StatusCreated || rec.Body.String() != "bob" || calls != 2 {
+		t.Fatalf("bob: %d %q calls=%d", rec.Code, rec.Body.String(), calls)
+	}
+}
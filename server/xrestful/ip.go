@@ -0,0 +1,220 @@
+package xrestful
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	restful "github.com/emicklei/go-restful/v3"
+	"github.com/system18188/jupiter-plugin/server/xrestful/errcode"
+)
+
+const attrClientIP = "xrestful.clientIP"
+
+// IPFilterConfig allow and deny lists of IPs or CIDRs, deny wins over allow.
+// An empty Allow list allows every address that is not denied.
+type IPFilterConfig struct {
+	Allow []string `json:"allow" toml:"allow"`
+	Deny  []string `json:"deny" toml:"deny"`
+	// 单个路由的名单, 替换server的名单
+	Routes []RouteIPFilter `json:"routes" toml:"routes"`
+}
+
+// RouteIPFilter allow and deny lists of one route
+type RouteIPFilter struct {
+	// 为空时匹配所有方法
+	Method string `json:"method" toml:"method"`
+	// 路由模板, 如 /admin/{name}
+	Path  string   `json:"path" toml:"path"`
+	Allow []string `json:"allow" toml:"allow"`
+	Deny  []string `json:"deny" toml:"deny"`
+}
+
+// ClientIP returns the client address resolved through the trusted proxies
+func ClientIP(req *restful.Request) string {
+	if ip, ok := req.Attribute(attrClientIP).(string); ok {
+		return ip
+	}
+	return remoteIP(req.Request)
+}
+
+// ipNets is a list of networks, bare IPs are single address networks
+type ipNets []*net.IPNet
+
+func parseIPNets(entries []string) (ipNets, error) {
+	nets := make(ipNets, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		switch {
+		case isCIDR(entry):
+			_, n, _ := net.ParseCIDR(entry)
+			nets = append(nets, n)
+		case isIP(entry):
+			ip := net.ParseIP(entry)
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+		default:
+			return nil, fmt.Errorf("invalid ip or cidr %q", entry)
+		}
+	}
+	return nets, nil
+}
+
+// isCIDR and isIP copy pkg/check.IsCIDR and pkg/check.IsIP, the repository root has no
+// go.mod for this module to require. Both are tested with pkg/check/testdata/ip.json.
+func isCIDR(s string) bool {
+	_, _, err := net.ParseCIDR(s)
+	return err == nil
+}
+
+func isIP(s string) bool {
+	return net.ParseIP(s) != nil
+}
+
+func (nets ipNets) contains(ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+type ipRule struct {
+	allow ipNets
+	deny  ipNets
+}
+
+func newIPRule(allow, deny []string) (*ipRule, error) {
+	a, err := parseIPNets(allow)
+	if err != nil {
+		return nil, err
+	}
+	d, err := parseIPNets(deny)
+	if err != nil {
+		return nil, err
+	}
+	return &ipRule{allow: a, deny: d}, nil
+}
+
+func (rule *ipRule) permit(ip net.IP) bool {
+	if ip == nil {
+		return len(rule.allow) == 0 && len(rule.deny) == 0
+	}
+	if rule.deny.contains(ip) {
+		return false
+	}
+	return len(rule.allow) == 0 || rule.allow.contains(ip)
+}
+
+// clientIPMiddleware resolves the client address once for the logs, traces and ClientIP
+func clientIPMiddleware(trusted ipNets) restful.FilterFunction {
+	return func(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+		req.SetAttribute(attrClientIP, resolveClientIP(req.Request, trusted))
+		chain.ProcessFilter(req, resp)
+	}
+}
+
+// ipFilterMiddleware answers 403 to addresses rejected by the server or route rules
+func ipFilterMiddleware(config *IPFilterConfig) (restful.FilterFunction, error) {
+	server, err := newIPRule(config.Allow, config.Deny)
+	if err != nil {
+		return nil, err
+	}
+	routes := make(map[string]*ipRule, len(config.Routes))
+	for _, route := range config.Routes {
+		rule, err := newIPRule(route.Allow, route.Deny)
+		if err != nil {
+			return nil, fmt.Errorf("route %s: %w", routeKey(route.Method, route.Path), err)
+		}
+		routes[routeKey(route.Method, route.Path)] = rule
+	}
+	return func(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+		path := req.SelectedRoutePath()
+		rule, ok := routes[routeKey(req.Request.Method, path)]
+		if !ok {
+			if rule, ok = routes[path]; !ok {
+				rule = server
+			}
+		}
+		if !rule.permit(net.ParseIP(ClientIP(req))) {
+			WriteError(req, resp, errcode.New(codeIPForbidden))
+			return
+		}
+		chain.ProcessFilter(req, resp)
+	}, nil
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// resolveClientIP walks Forwarded or X-Forwarded-For from the right, skipping
+// trusted proxies. The headers are ignored when the peer itself is not trusted.
+func resolveClientIP(r *http.Request, trusted ipNets) string {
+	remote := remoteIP(r)
+	if !trusted.contains(net.ParseIP(remote)) {
+		return remote
+	}
+	hops := forwardedFor(r.Header.Values("Forwarded"))
+	if len(hops) == 0 {
+		for _, h := range r.Header.Values("X-Forwarded-For") {
+			for _, hop := range strings.Split(h, ",") {
+				hops = append(hops, strings.TrimSpace(hop))
+			}
+		}
+	}
+	if len(hops) == 0 {
+		if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); isIP(ip) {
+			return ip
+		}
+		return remote
+	}
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(hops[i])
+		if ip == nil {
+			// unknown 或混淆的节点之后的地址都不可信
+			break
+		}
+		client = ip.String()
+		if !trusted.contains(ip) {
+			break
+		}
+	}
+	return client
+}
+
+// forwardedFor returns the for= addresses of RFC 7239 Forwarded headers without ports
+func forwardedFor(headers []string) []string {
+	var hops []string
+	for _, h := range headers {
+		for _, element := range strings.Split(h, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+				if len(kv) != 2 || !strings.EqualFold(kv[0], "for") {
+					continue
+				}
+				node := strings.Trim(kv[1], `"`)
+				if strings.HasPrefix(node, "[") {
+					// [2001:db8::1]:4711
+					if end := strings.Index(node, "]"); end > 0 {
+						node = node[1:end]
+					}
+				} else if host, _, err := net.SplitHostPort(node); err == nil {
+					node = host
+				}
+				hops = append(hops, node)
+			}
+		}
+	}
+	return hops
+}
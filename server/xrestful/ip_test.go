@@ -0,0 +1,99 @@
+package xrestful
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	restful "github.com/emicklei/go-restful/v3"
+)
+
+func TestResolveClientIP(t *testing.T) {
+	trusted, err := parseIPNets([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		remote, header, value, want string
+	}{
+		{"1.2.3.4:80", "X-Forwarded-For", "9.9.9.9", "1.2.3.4"},
+		{"10.0.0.1:80", "X-Forwarded-For", "9.9.9.9, 5.5.5.5, 192.168.1.1", "5.5.5.5"},
+		{"10.0.0.1:80", "X-Forwarded-For", "10.0.0.2, 10.0.0.3", "10.0.0.2"},
+		{"10.0.0.1:80", "X-Forwarded-For", "9.9.9.9, unknown, 10.0.0.3", "10.0.0.3"},
+		{"10.0.0.1:80", "Forwarded", `for=9.9.9.9, for="[2001:db8::1]:4711";proto=https, for=10.0.0.2:80`, "2001:db8::1"},
+		{"10.0.0.1:80", "X-Real-IP", "5.5.5.5", "5.5.5.5"},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = c.remote
+		r.Header.Set(c.header, c.value)
+		if got := resolveClientIP(r, trusted); got != c.want {
+			t.Errorf("%s %s=%q: %s, want %s", c.remote, c.header, c.value, got, c.want)
+		}
+	}
+
+	if _, err := parseIPNets([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("invalid cidr accepted")
+	}
+}
+
+func TestIPFilter(t *testing.T) {
+	filter, err := ipFilterMiddleware(&IPFilterConfig{
+		Deny: []string{"1.2.3.0/24"},
+		Routes: []RouteIPFilter{
+			{Method: http.MethodGet, Path: "/admin", Allow: []string{"10.0.0.0/8"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws := new(restful.WebService)
+	ok := func(req *restful.Request, resp *restful.Response) {}
+	ws.Route(ws.GET("/admin").To(ok))
+	ws.Route(ws.GET("/public").To(ok))
+	container := restful.NewContainer()
+	container.Filter(filter)
+	container.Add(ws)
+
+	cases := []struct {
+		path, remote string
+		code         int
+	}{
+		{"/public", "8.8.8.8:1", http.StatusOK},
+		{"/public", "1.2.3.4:1", http.StatusForbidden},
+		{"/admin", "8.8.8.8:1", http.StatusForbidden},
+		{"/admin", "10.1.1.1:1", http.StatusOK},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, c.path, nil)
+		r.RemoteAddr = c.remote
+		rec := httptest.NewRecorder()
+		container.ServeHTTP(rec, r)
+		if rec.Code != c.code {
+			t.Errorf("%s from %s: %d, want %d", c.path, c.remote, rec.Code, c.code)
+		}
+	}
+}
+
+// isIP and isCIDR are checked against the table of pkg/check so the copies stay in sync
+func TestIsIPAndCIDR(t *testing.T) {
+	b, err := ioutil.ReadFile("../../pkg/check/testdata/ip.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cases []struct {
+		In   string `json:"in"`
+		IP   bool   `json:"ip"`
+		CIDR bool   `json:"cidr"`
+	}
+	if err := json.Unmarshal(b, &cases); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cases {
+		if isIP(c.In) != c.IP || isCIDR(c.In) != c.CIDR {
+			t.Errorf("%q: isIP %v isCIDR %v", c.In, isIP(c.In), isCIDR(c.In))
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package xrestful
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/douyu/jupiter/pkg/metric"
+	restful "github.com/emicklei/go-restful/v3"
+	"github.com/system18188/jupiter-plugin/server/xrestful/errcode"
+)
+
+// Priority decides whether a request may be shed under overload
+type Priority int
+
+const (
+	// PrioritySheddable requests are shed first, once in-flight reaches limit*SheddableRatio
+	PrioritySheddable Priority = iota - 1
+	// PriorityNormal requests are shed once in-flight reaches the limit
+	PriorityNormal
+	// PriorityCritical requests are never shed, e.g. health checks and admin routes
+	PriorityCritical
+)
+
+// String ...
+func (p Priority) String() string {
+	switch p {
+	case PrioritySheddable:
+		return "sheddable"
+	case PriorityCritical:
+		return "critical"
+	default:
+		return "normal"
+	}
+}
+
+var (
+	limiterLimitGauge = metric.GaugeVecOpts{
+		Namespace: metric.DefaultNamespace,
+		Name:      "server_limiter_limit",
+		Labels:    []string{"type", "scope"},
+	}.Build()
+	limiterInflightGauge = metric.GaugeVecOpts{
+		Namespace: metric.DefaultNamespace,
+		Name:      "server_limiter_inflight",
+		Labels:    []string{"type", "scope"},
+	}.Build()
+	limiterShedCounter = metric.CounterVecOpts{
+		Namespace: metric.DefaultNamespace,
+		Name:      "server_limiter_shed_total",
+		Labels:    []string{"type", "scope", "priority"},
+	}.Build()
+)
+
+// LimiterConfig adaptive concurrency limit options
+type LimiterConfig struct {
+	Enable bool `json:"enable" toml:"enable"`
+	// 限流算法 aimd 或 gradient, 默认 gradient
+	Algorithm string `json:"algorithm" toml:"algorithm"`
+	// 初始并发数
+	InitialLimit int `json:"initialLimit" toml:"initialLimit"`
+	// 并发数下限
+	MinLimit int `json:"minLimit" toml:"minLimit"`
+	// 整个server的并发数上限
+	MaxLimit int `json:"maxLimit" toml:"maxLimit"`
+	// 单个路由的并发数上限, 0表示只限制整个server
+	RouteMaxLimit int `json:"routeMaxLimit" toml:"routeMaxLimit"`
+	// 请求耗时超过Timeout视为过载
+	Timeout time.Duration `json:"timeout" toml:"timeout"`
+	// aimd 过载时并发数的衰减比例
+	BackoffRatio float64 `json:"backoffRatio" toml:"backoffRatio"`
+	// gradient 允许的延迟上涨倍数
+	Tolerance float64 `json:"tolerance" toml:"tolerance"`
+	// 503 响应的 Retry-After 秒数
+	RetryAfter int `json:"retryAfter" toml:"retryAfter"`
+	// 永不丢弃的路径前缀, 如 /health /admin
+	CriticalPaths []string `json:"criticalPaths" toml:"criticalPaths"`
+	// 优先丢弃的路径前缀
+	SheddablePaths []string `json:"sheddablePaths" toml:"sheddablePaths"`
+	// 低优先级请求在并发达到 limit*SheddableRatio 时丢弃
+	SheddableRatio float64 `json:"sheddableRatio" toml:"sheddableRatio"`
+	// 自定义优先级, 优先于CriticalPaths和SheddablePaths
+	PriorityFunc func(req *restful.Request) Priority `json:"-" toml:"-"`
+}
+
+// DefaultLimiterConfig ...
+func DefaultLimiterConfig() LimiterConfig {
+	return LimiterConfig{
+		Algorithm:      "gradient",
+		InitialLimit:   20,
+		MinLimit:       1,
+		MaxLimit:       1000,
+		Timeout:        time.Second,
+		BackoffRatio:   0.9,
+		Tolerance:      1.5,
+		RetryAfter:     1,
+		SheddableRatio: 0.8,
+	}
+}
+
+func (config *LimiterConfig) priority(req *restful.Request) Priority {
+	if config.PriorityFunc != nil {
+		return config.PriorityFunc(req)
+	}
+	path := req.Request.URL.Path
+	for _, prefix := range config.CriticalPaths {
+		if strings.HasPrefix(path, prefix) {
+			return PriorityCritical
+		}
+	}
+	for _, prefix := range config.SheddablePaths {
+		if strings.HasPrefix(path, prefix) {
+			return PrioritySheddable
+		}
+	}
+	return PriorityNormal
+}
+
+func (config *LimiterConfig) newLimiter(scope string, max int) *adaptiveLimiter {
+	var algorithm limitAlgorithm
+	switch config.Algorithm {
+	case "aimd":
+		algorithm = &aimdLimit{timeout: config.Timeout, backoffRatio: config.BackoffRatio}
+	default:
+		algorithm = &gradientLimit{tolerance: config.Tolerance, smoothing: 0.2}
+	}
+	limit := float64(config.InitialLimit)
+	if limit > float64(max) {
+		limit = float64(max)
+	}
+	l := &adaptiveLimiter{
+		scope:     scope,
+		limit:     limit,
+		min:       float64(config.MinLimit),
+		max:       float64(max),
+		algorithm: algorithm,
+	}
+	limiterLimitGauge.Set(l.limit, metric.TypeHTTP, scope)
+	return l
+}
+
+// limiterMiddleware sheds requests over the adaptive server and route limits with 503
+func limiterMiddleware(config *LimiterConfig) restful.FilterFunction {
+	server := config.newLimiter("server", config.MaxLimit)
+	var routes sync.Map
+	routeLimiter := func(path string) *adaptiveLimiter {
+		if l, ok := routes.Load(path); ok {
+			return l.(*adaptiveLimiter)
+		}
+		l, _ := routes.LoadOrStore(path, config.newLimiter(path, config.RouteMaxLimit))
+		return l.(*adaptiveLimiter)
+	}
+	shed := func(req *restful.Request, resp *restful.Response, scope string, priority Priority) {
+		limiterShedCounter.Inc(metric.TypeHTTP, scope, priority.String())
+		resp.AddHeader("Retry-After", strconv.Itoa(config.RetryAfter))
+		WriteError(req, resp, errcode.New(codeOverloaded))
+	}
+
+	return func(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+		priority := config.priority(req)
+		if !server.acquire(priority, config.SheddableRatio) {
+			shed(req, resp, server.scope, priority)
+			return
+		}
+		var route *adaptiveLimiter
+		if config.RouteMaxLimit > 0 {
+			route = routeLimiter(req.SelectedRoutePath())
+			if !route.acquire(priority, config.SheddableRatio) {
+				server.cancel()
+				shed(req, resp, route.scope, priority)
+				return
+			}
+		}
+
+		beg := time.Now()
+		dropped := true
+		defer func() {
+			rtt := time.Since(beg)
+			server.release(rtt, dropped)
+			if route != nil {
+				route.release(rtt, dropped)
+			}
+		}()
+		chain.ProcessFilter(req, resp)
+		// 下游超时或不可用同样视为过载信号
+		dropped = resp.StatusCode() == http.StatusServiceUnavailable || resp.StatusCode() == http.StatusGatewayTimeout
+	}
+}
+
+// adaptiveLimiter is a concurrency limit adjusted by a limitAlgorithm after every request
+type adaptiveLimiter struct {
+	scope     string
+	mu        sync.Mutex
+	limit     float64
+	inflight  int
+	min       float64
+	max       float64
+	algorithm limitAlgorithm
+}
+
+func (l *adaptiveLimiter) acquire(priority Priority, sheddableRatio float64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if priority != PriorityCritical {
+		threshold := l.limit
+		if priority == PrioritySheddable {
+			threshold *= sheddableRatio
+		}
+		if float64(l.inflight) >= math.Max(threshold, 1) {
+			return false
+		}
+	}
+	l.inflight++
+	limiterInflightGauge.Set(float64(l.inflight), metric.TypeHTTP, l.scope)
+	return true
+}
+
+// cancel gives back an acquired slot without a latency sample
+func (l *adaptiveLimiter) cancel() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inflight--
+	limiterInflightGauge.Set(float64(l.inflight), metric.TypeHTTP, l.scope)
+}
+
+func (l *adaptiveLimiter) release(rtt time.Duration, dropped bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	limit := l.algorithm.update(l.limit, rtt, l.inflight, dropped)
+	l.limit = math.Min(math.Max(limit, l.min), l.max)
+	l.inflight--
+	limiterLimitGauge.Set(l.limit, metric.TypeHTTP, l.scope)
+	limiterInflightGauge.Set(float64(l.inflight), metric.TypeHTTP, l.scope)
+}
+
+func (l *adaptiveLimiter) currentLimit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+type limitAlgorithm interface {
+	update(limit float64, rtt time.Duration, inflight int, dropped bool) float64
+}
+
+// aimdLimit additive increase, multiplicative decrease on timeouts and drops
+type aimdLimit struct {
+	timeout      time.Duration
+	backoffRatio float64
+}
+
+func (a *aimdLimit) update(limit float64, rtt time.Duration, inflight int, dropped bool) float64 {
+	if dropped || rtt > a.timeout {
+		return limit * a.backoffRatio
+	}
+	// 只有并发数被用到一半以上时才增加, 避免空闲时无限增长
+	if float64(inflight)*2 >= limit {
+		return limit + 1
+	}
+	return limit
+}
+
+// gradientLimit follows the ratio between the long term and the current latency,
+// see Netflix concurrency-limits Gradient2Limit
+type gradientLimit struct {
+	tolerance float64
+	smoothing float64
+	longRTT   float64
+}
+
+func (g *gradientLimit) update(limit float64, rtt time.Duration, inflight int, dropped bool) float64 {
+	short := float64(rtt)
+	if g.longRTT == 0 {
+		g.longRTT = short
+	}
+	g.longRTT = g.longRTT*0.99 + short*0.01
+	// 长期延迟高于当前延迟时让其快速回落, 避免过载恢复后长时间限流
+	if g.longRTT/short > 2 {
+		g.longRTT *= 0.95
+	}
+	if dropped {
+		return limit * 0.9
+	}
+	gradient := math.Max(0.5, math.Min(1.0, g.tolerance*g.longRTT/short))
+	if gradient == 1 && float64(inflight) < limit/2 {
+		return limit
+	}
+	newLimit := limit*gradient + math.Sqrt(limit)
+	return limit*(1-g.smoothing) + newLimit*g.smoothing
+}
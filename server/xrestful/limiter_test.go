@@ -0,0 +1,47 @@
+package xrestful
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdaptiveLimiterShed(t *testing.T) {
+	config := DefaultLimiterConfig()
+	config.Algorithm = "aimd"
+	config.InitialLimit = 2
+	l := config.newLimiter("test", 10)
+
+	if !l.acquire(PriorityNormal, config.SheddableRatio) || !l.acquire(PriorityNormal, config.SheddableRatio) {
+		t.Fatal("should acquire up to the limit")
+	}
+	if l.acquire(PriorityNormal, config.SheddableRatio) {
+		t.Fatal("normal request over the limit should be shed")
+	}
+	if !l.acquire(PriorityCritical, config.SheddableRatio) {
+		t.Fatal("critical request should never be shed")
+	}
+
+	l.release(time.Millisecond, false)
+	if got := l.currentLimit(); got != 3 {
+		t.Fatalf("additive increase: limit = %d", got)
+	}
+	l.release(2*time.Second, false)
+	if got := l.currentLimit(); got != 2 {
+		t.Fatalf("multiplicative decrease: limit = %d", got)
+	}
+}
+
+func TestAdaptiveLimiterSheddable(t *testing.T) {
+	config := DefaultLimiterConfig()
+	config.InitialLimit = 5
+	l := config.newLimiter("test", 10)
+	for i := 0; i < 4; i++ {
+		l.acquire(PriorityNormal, config.SheddableRatio)
+	}
+	if l.acquire(PrioritySheddable, config.SheddableRatio) {
+		t.Fatal("sheddable request should be shed at limit*SheddableRatio")
+	}
+	if !l.acquire(PriorityNormal, config.SheddableRatio) {
+		t.Fatal("normal request should pass below the limit")
+	}
+}
@@ -0,0 +1,124 @@
+package xrestful
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// listen creates the listener described by config: a systemd socket when
+// SocketActivation is on and one was passed, otherwise a tcp or unix socket.
+func (config *Config) listen() (net.Listener, error) {
+	if config.SocketActivation {
+		listener, err := activationListener(config.SocketActivationName)
+		if err != nil {
+			return nil, err
+		}
+		if listener != nil {
+			return listener, nil
+		}
+		config.logger.Info("no systemd listener, fallback to listen")
+	}
+
+	network := config.Network
+	if network == "" {
+		network = "tcp"
+	}
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		lc := net.ListenConfig{}
+		if config.ReusePort {
+			lc.Control = reusePortControl
+		}
+		return lc.Listen(context.Background(), network, config.Address())
+	case "unix":
+		return config.listenUnix()
+	default:
+		return nil, fmt.Errorf("unsupported network %q", network)
+	}
+}
+
+func (config *Config) listenUnix() (net.Listener, error) {
+	if config.SocketPath == "" {
+		return nil, fmt.Errorf("empty socketPath for unix network")
+	}
+	// 上次进程异常退出时会残留socket文件, 只删除socket类型的文件
+	if fi, err := os.Lstat(config.SocketPath); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(config.SocketPath); err != nil {
+			return nil, err
+		}
+	}
+	listener, err := net.Listen("unix", config.SocketPath)
+	if err != nil {
+		return nil, err
+	}
+	if config.SocketFileMode != "" {
+		mode, err := strconv.ParseUint(config.SocketFileMode, 8, 32)
+		if err != nil {
+			listener.Close()
+			return nil, fmt.Errorf("invalid socketFileMode %q: %w", config.SocketFileMode, err)
+		}
+		if err := os.Chmod(config.SocketPath, os.FileMode(mode)); err != nil {
+			listener.Close()
+			return nil, err
+		}
+	}
+	return listener, nil
+}
+
+// listenFdsStart is the first file descriptor passed by systemd, see sd_listen_fds(3)
+const listenFdsStart = 3
+
+var activation struct {
+	sync.Mutex
+	adopted map[int]bool
+}
+
+// activationListener returns an unused listener passed by systemd, matching
+// name when it is not empty. It returns nil when no listener was passed.
+func activationListener(name string) (net.Listener, error) {
+	if pid, err := strconv.Atoi(os.Getenv("LISTEN_PID")); err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	activation.Lock()
+	defer activation.Unlock()
+	if activation.adopted == nil {
+		activation.adopted = make(map[int]bool)
+	}
+	for i := 0; i < n; i++ {
+		fd := listenFdsStart + i
+		if activation.adopted[fd] {
+			continue
+		}
+		fdName := ""
+		if i < len(names) {
+			fdName = names[i]
+		}
+		if name != "" && name != fdName {
+			continue
+		}
+		// FileListener 会复制fd, 原文件可以直接关闭
+		f := os.NewFile(uintptr(fd), fdName)
+		listener, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("adopt systemd fd %d: %w", fd, err)
+		}
+		activation.adopted[fd] = true
+		return listener, nil
+	}
+	if name != "" {
+		return nil, fmt.Errorf("no systemd listener named %q", name)
+	}
+	return nil, nil
+}
@@ -0,0 +1,46 @@
+package xrestful
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListenUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "xrestful.sock")
+	config := DefaultConfig()
+	config.Network = "unix"
+	config.SocketPath = path
+	config.SocketFileMode = "0600"
+	listener, err := config.listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	fi, err := os.Stat(path)
+	if err != nil || fi.Mode().Perm() != 0600 {
+		t.Fatalf("stat %v %v", fi, err)
+	}
+
+	info := newServer(config.WithListener(listener)).Info()
+	if info.Scheme != "http+unix" || info.Address != path {
+		t.Fatalf("info = %s %s", info.Scheme, info.Address)
+	}
+}
+
+func TestListenReusePort(t *testing.T) {
+	config := DefaultConfig().WithHost("127.0.0.1").WithPort(0)
+	config.ReusePort = true
+	l1, err := config.listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l1.Close()
+	config.Port = l1.Addr().(*net.TCPAddr).Port
+	l2, err := config.listen()
+	if err != nil {
+		t.Fatalf("second listen on %d: %v", config.Port, err)
+	}
+	l2.Close()
+}
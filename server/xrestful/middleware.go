@@ -7,6 +7,7 @@ import (
 	"github.com/douyu/jupiter/pkg/trace"
 	"github.com/douyu/jupiter/pkg/xlog"
 	restful "github.com/emicklei/go-restful/v3"
+	"github.com/system18188/jupiter-plugin/server/xrestful/errcode"
 	"go.uber.org/zap"
 	"io/ioutil"
 	"net"
@@ -28,17 +29,21 @@ func extractAID(req *restful.Request) string {
 	return req.Request.Header.Get("AID")
 }
 
-func recoverMiddleware(logger *xlog.Logger, slowQueryThresholdInMilli int64) restful.FilterFunction {
+func recoverMiddleware(config *Config) restful.FilterFunction {
+	logger := config.logger
 	return func(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
 		var beg = time.Now()
 		var fields = make([]xlog.Field, 0, 8)
 		var brokenPipe bool
 		defer func() {
+			opts := config.options()
+			slow := false
 			//Latency
 			fields = append(fields, zap.Float64("cost", time.Since(beg).Seconds()))
-			if slowQueryThresholdInMilli > 0 {
-				if cost := int64(time.Since(beg)) / 1e6; cost > slowQueryThresholdInMilli {
+			if opts.slowQueryThresholdInMilli > 0 {
+				if cost := int64(time.Since(beg)) / 1e6; cost > opts.slowQueryThresholdInMilli {
 					fields = append(fields, zap.Int64("slow", cost))
+					slow = true
 				}
 			}
 			if rec := recover(); rec != nil {
@@ -55,21 +60,27 @@ func recoverMiddleware(logger *xlog.Logger, slowQueryThresholdInMilli int64) res
 				logger.Error("access", fields...)
 				// If the connection is dead, we can't write a status to it.
 				if brokenPipe {
-					resp.WriteErrorString(http.StatusInternalServerError, err.Error())
 					return
 				}
-				resp.WriteHeader(http.StatusInternalServerError)
+				WriteError(req, resp, errcode.New(codeMS))
+				return
+			}
+			// 错误和慢请求不参与采样
+			if !slow && resp.StatusCode() < http.StatusInternalServerError && !opts.sampled() {
 				return
 			}
 			// httpRequest, _ := httputil.DumpRequest(c.Request, false)
 			// fields = append(fields, zap.ByteString("request", httpRequest))
+			if opts.debug {
+				fields = append(fields, zap.String("query", req.Request.URL.RawQuery))
+			}
 			fields = append(fields,
 				zap.String("method", req.Request.Method),
 				zap.Int("code", resp.StatusCode()),
 				zap.Int("size", resp.ContentLength()),
 				zap.String("host", req.Request.Host),
 				zap.String("path", req.Request.URL.Path),
-				zap.String("ip", clientIP(req)),
+				zap.String("ip", ClientIP(req)),
 			)
 			logger.Info("access", fields...)
 		}()
@@ -164,7 +175,7 @@ func traceServerInterceptor() restful.FilterFunction {
 			trace.HeaderExtractor(req.Request.Header),
 			trace.CustomTag("http.url", req.Request.URL.Path),
 			trace.CustomTag("http.method", req.Request.Method),
-			trace.CustomTag("peer.ipv4", clientIP(req)),
+			trace.CustomTag("peer.ipv4", ClientIP(req)),
 		)
 		req.Request.WithContext(ctx)
 		defer span.Finish()
@@ -172,16 +183,3 @@ func traceServerInterceptor() restful.FilterFunction {
 		chain.ProcessFilter(req, resp)
 	}
 }
-
-// IP returns the IP address of request.
-func clientIP(req *restful.Request) string {
-	ra := req.Request.RemoteAddr
-	if ip := req.HeaderParameter("X-Forwarded-For"); ip != "" {
-		ra = strings.Split(ip, ", ")[0]
-	} else if ip := req.HeaderParameter("X-Real-IP"); ip != "" {
-		ra = ip
-	} else {
-		ra, _, _ = net.SplitHostPort(ra)
-	}
-	return ra
-}
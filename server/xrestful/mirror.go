@@ -0,0 +1,276 @@
+package xrestful
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/douyu/jupiter/pkg/metric"
+	"github.com/douyu/jupiter/pkg/xlog"
+	restful "github.com/emicklei/go-restful/v3"
+)
+
+// HeaderShadowRequest marks the copies sent to the shadow upstream
+const HeaderShadowRequest = "X-Shadow-Request"
+
+var mirrorCounter = metric.CounterVecOpts{
+	Namespace: metric.DefaultNamespace,
+	Name:      "server_mirror_total",
+	Labels:    []string{"type", "method", "path", "result"},
+}.Build()
+
+// MirrorConfig copies sampled requests to a shadow upstream, the client only
+// gets the response of the server
+type MirrorConfig struct {
+	// 影子服务地址, 如 http://127.0.0.1:8080
+	Upstream string `json:"upstream" toml:"upstream"`
+	// 采样比例 0~1
+	Sampling float64 `json:"sampling" toml:"sampling"`
+	// 影子请求超时, 默认1秒
+	Timeout time.Duration `json:"timeout" toml:"timeout"`
+	// 比较影子响应的状态码和body哈希, 不一致时记录日志
+	Compare bool `json:"compare" toml:"compare"`
+	// 请求体超过该大小时不镜像, 默认1MB
+	MaxBodySize int64 `json:"maxBodySize" toml:"maxBodySize"`
+	// 同时进行的影子请求上限, 超过时丢弃, 默认64
+	Concurrency int `json:"concurrency" toml:"concurrency"`
+	// 需要镜像的路由, 为空时镜像所有路由
+	Routes []RouteMirror `json:"routes" toml:"routes"`
+}
+
+// RouteMirror mirror options of one route
+type RouteMirror struct {
+	// 为空时匹配所有方法
+	Method string `json:"method" toml:"method"`
+	// 路由模板, 如 /users/{id}
+	Path string `json:"path" toml:"path"`
+	// 为空时使用server的Upstream
+	Upstream string `json:"upstream" toml:"upstream"`
+	// 为0时使用server的Sampling
+	Sampling float64 `json:"sampling" toml:"sampling"`
+}
+
+func (config *MirrorConfig) enabled() bool {
+	return config.Upstream != "" || len(config.Routes) > 0
+}
+
+type mirrorRule struct {
+	upstream *url.URL
+	sampling float64
+}
+
+type mirror struct {
+	config *MirrorConfig
+	logger *xlog.Logger
+	client *http.Client
+	// 为nil时只镜像routes中的路由
+	server *mirrorRule
+	routes map[string]*mirrorRule
+	sem    chan struct{}
+}
+
+func newMirrorRule(upstream string, sampling float64) (*mirrorRule, error) {
+	u, err := url.Parse(upstream)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid upstream %q", upstream)
+	}
+	return &mirrorRule{upstream: u, sampling: sampling}, nil
+}
+
+// mirrorMiddleware sends copies of the sampled requests after they were served
+func mirrorMiddleware(config *MirrorConfig, logger *xlog.Logger) (restful.FilterFunction, error) {
+	m := &mirror{
+		config: config,
+		logger: logger,
+		routes: make(map[string]*mirrorRule, len(config.Routes)),
+	}
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+	m.client = &http.Client{
+		Timeout: timeout,
+		// 影子服务的重定向不需要跟随
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	concurrency := config.Concurrency
+	if concurrency <= 0 {
+		concurrency = 64
+	}
+	m.sem = make(chan struct{}, concurrency)
+
+	if len(config.Routes) == 0 {
+		rule, err := newMirrorRule(config.Upstream, config.Sampling)
+		if err != nil {
+			return nil, err
+		}
+		m.server = rule
+	}
+	for _, route := range config.Routes {
+		upstream, sampling := route.Upstream, route.Sampling
+		if upstream == "" {
+			upstream = config.Upstream
+		}
+		if sampling == 0 {
+			sampling = config.Sampling
+		}
+		rule, err := newMirrorRule(upstream, sampling)
+		if err != nil {
+			return nil, fmt.Errorf("route %s: %w", routeKey(route.Method, route.Path), err)
+		}
+		m.routes[routeKey(route.Method, route.Path)] = rule
+	}
+	return m.filter, nil
+}
+
+func (m *mirror) rule(method, path string) *mirrorRule {
+	if rule, ok := m.routes[routeKey(method, path)]; ok {
+		return rule
+	}
+	if rule, ok := m.routes[path]; ok {
+		return rule
+	}
+	return m.server
+}
+
+func (m *mirror) filter(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+	path := req.SelectedRoutePath()
+	rule := m.rule(req.Request.Method, path)
+	if rule == nil || rule.sampling <= 0 || (rule.sampling < 1 && rand.Float64() >= rule.sampling) {
+		chain.ProcessFilter(req, resp)
+		return
+	}
+	body, ok := m.readBody(req.Request)
+	if !ok {
+		chain.ProcessFilter(req, resp)
+		return
+	}
+	shadow, err := m.newRequest(rule, req.Request, body)
+	if err != nil {
+		m.logger.Warn("mirror request", xlog.FieldErr(err), xlog.FieldMethod(req.Request.Method), xlog.String("path", path))
+		chain.ProcessFilter(req, resp)
+		return
+	}
+
+	status, hash := 0, ""
+	if m.config.Compare {
+		rec := recordResponse(resp, func() {
+			chain.ProcessFilter(req, resp)
+		})
+		status, hash = rec.StatusCode(), bodyHash(bytes.NewReader(rec.body.Bytes()))
+		rec.flushTo(resp.ResponseWriter)
+	} else {
+		chain.ProcessFilter(req, resp)
+	}
+
+	select {
+	case m.sem <- struct{}{}:
+	default:
+		mirrorCounter.Inc(metric.TypeHTTP, req.Request.Method, path, "dropped")
+		return
+	}
+	go func() {
+		defer func() { <-m.sem }()
+		m.send(shadow, path, status, hash)
+	}()
+}
+
+// readBody returns the body for the shadow copy and restores it for the handler,
+// false when the body is too large to be mirrored
+func (m *mirror) readBody(r *http.Request) ([]byte, bool) {
+	max := m.config.MaxBodySize
+	if max <= 0 {
+		max = 1 << 20
+	}
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true
+	}
+	if r.ContentLength > max {
+		return nil, false
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, max+1))
+	// 未读完的部分接在后面, handler 仍能读到完整的请求体
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	if err != nil || int64(len(body)) > max {
+		return nil, false
+	}
+	return body, true
+}
+
+func (m *mirror) newRequest(rule *mirrorRule, r *http.Request, body []byte) (*http.Request, error) {
+	u := *rule.upstream
+	u.Path = strings.TrimSuffix(u.Path, "/") + r.URL.Path
+	u.RawQuery = r.URL.RawQuery
+	shadow, err := http.NewRequest(r.Method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	copyHeader(shadow.Header, r.Header)
+	for _, h := range hopHeaders {
+		shadow.Header.Del(h)
+	}
+	shadow.Header.Set(HeaderShadowRequest, "1")
+	if ip := remoteIP(r); ip != "" {
+		shadow.Header.Add("X-Forwarded-For", ip)
+	}
+	return shadow, nil
+}
+
+// hopHeaders are not forwarded to the shadow upstream, RFC 7230 section 6.1
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+func (m *mirror) send(shadow *http.Request, path string, status int, hash string) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.client.Timeout)
+	defer cancel()
+	resp, err := m.client.Do(shadow.WithContext(ctx))
+	if err != nil {
+		mirrorCounter.Inc(metric.TypeHTTP, shadow.Method, path, "error")
+		m.logger.Warn("mirror request", xlog.FieldErr(err), xlog.FieldMethod(shadow.Method), xlog.String("path", path))
+		return
+	}
+	defer resp.Body.Close()
+	if !m.config.Compare {
+		io.Copy(ioutil.Discard, resp.Body)
+		mirrorCounter.Inc(metric.TypeHTTP, shadow.Method, path, "sent")
+		return
+	}
+	shadowHash := bodyHash(resp.Body)
+	if resp.StatusCode == status && shadowHash == hash {
+		mirrorCounter.Inc(metric.TypeHTTP, shadow.Method, path, "same")
+		return
+	}
+	mirrorCounter.Inc(metric.TypeHTTP, shadow.Method, path, "diff")
+	m.logger.Warn("mirror response differs", xlog.FieldMethod(shadow.Method), xlog.String("path", path),
+		xlog.String("url", shadow.URL.RequestURI()), xlog.Int("status", status), xlog.Int("shadowStatus", resp.StatusCode),
+		xlog.String("hash", hash), xlog.String("shadowHash", shadowHash))
+}
+
+func bodyHash(r io.Reader) string {
+	h := sha256.New()
+	io.Copy(h, r)
+	return hex.EncodeToString(h.Sum(nil))
+}
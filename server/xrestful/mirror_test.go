@@ -0,0 +1,81 @@
+package xrestful
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/douyu/jupiter/pkg/conf"
+	restful "github.com/emicklei/go-restful/v3"
+)
+
+type shadowRequest struct {
+	method, uri, body, header string
+}
+
+func TestMirror(t *testing.T) {
+	shadows := make(chan shadowRequest, 4)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		shadows <- shadowRequest{r.Method, r.URL.RequestURI(), string(body), r.Header.Get(HeaderShadowRequest)}
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer upstream.Close()
+
+	c := conf.New()
+	err := c.LoadFromReader(strings.NewReader(`{"server": {"mirror": {
+		"upstream": "`+upstream.URL+`/shadow", "sampling": 1, "timeout": "1s", "compare": true,
+		"routes": [{"method": "POST", "path": "/users/{id}"}, {"path": "/orders", "sampling": 0.0001}]
+	}}}`), json.Unmarshal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := DefaultConfig()
+	if err := c.UnmarshalKey("server", config); err != nil {
+		t.Fatal(err)
+	}
+	if config.Mirror.Timeout != time.Second || len(config.Mirror.Routes) != 2 {
+		t.Fatalf("mirror config = %+v", config.Mirror)
+	}
+	filter, err := mirrorMiddleware(&config.Mirror, config.logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	container := restful.NewContainer()
+	container.Filter(filter)
+	ws := new(restful.WebService)
+	handler := func(req *restful.Request, resp *restful.Response) {
+		body, _ := ioutil.ReadAll(req.Request.Body)
+		resp.Write(body)
+	}
+	ws.Route(ws.POST("/users/{id}").To(handler))
+	ws.Route(ws.GET("/users/{id}").To(handler))
+	container.Add(ws)
+
+	rec := httptest.NewRecorder()
+	container.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/users/1?a=b", strings.NewReader("hello")))
+	if rec.Code != http.StatusOK || rec.Body.String() != "hello" {
+		t.Fatalf("client response %d %q", rec.Code, rec.Body.String())
+	}
+	select {
+	case shadow := <-shadows:
+		if shadow != (shadowRequest{http.MethodPost, "/shadow/users/1?a=b", "hello", "1"}) {
+			t.Errorf("shadow = %+v", shadow)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("request not mirrored")
+	}
+
+	// 未配置的路由不镜像
+	container.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	select {
+	case shadow := <-shadows:
+		t.Errorf("unexpected shadow %+v", shadow)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,199 @@
+package xrestful
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/douyu/jupiter/pkg/xlog"
+	restful "github.com/emicklei/go-restful/v3"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/system18188/jupiter-plugin/server/xrestful/errcode"
+)
+
+// ValidationError is one violation of the OpenAPI document, the violations of a
+// request are written by WriteError in the Details of ErrorBody
+type ValidationError struct {
+	// JSON pointer of the invalid value: /path/{name}, /query/{name}, /header/{name} or /body/...
+	Location string `json:"location"`
+	Message  string `json:"message"`
+}
+
+// OpenAPIConfig contract-first validation options
+type OpenAPIConfig struct {
+	// OpenAPI 3 文档, json 或 yaml
+	SpecFile string
+	// 文档servers中的路径前缀, 与文档中的path匹配前从路由中去掉
+	BasePath string
+	// 同时校验响应, 不一致时记录日志, 用于debug和测试
+	ValidateResponse bool
+
+	logger *xlog.Logger
+	doc    *openapi3.T
+}
+
+// DefaultOpenAPIConfig ...
+func DefaultOpenAPIConfig() *OpenAPIConfig {
+	return &OpenAPIConfig{
+		logger: xlog.JupiterLogger.With(xlog.FieldMod(ModName)),
+	}
+}
+
+// WithSpecFile ...
+func (config *OpenAPIConfig) WithSpecFile(file string) *OpenAPIConfig {
+	config.SpecFile = file
+	return config
+}
+
+// WithSpec uses a loaded document instead of SpecFile
+func (config *OpenAPIConfig) WithSpec(doc *openapi3.T) *OpenAPIConfig {
+	config.doc = doc
+	return config
+}
+
+// WithBasePath ...
+func (config *OpenAPIConfig) WithBasePath(basePath string) *OpenAPIConfig {
+	config.BasePath = basePath
+	return config
+}
+
+// WithValidateResponse ...
+func (config *OpenAPIConfig) WithValidateResponse(validate bool) *OpenAPIConfig {
+	config.ValidateResponse = validate
+	return config
+}
+
+// WithLogger ...
+func (config *OpenAPIConfig) WithLogger(logger *xlog.Logger) *OpenAPIConfig {
+	config.logger = logger
+	return config
+}
+
+// Build loads and checks the document then create the validation filter,
+// routes missing from the document are not validated
+func (config *OpenAPIConfig) Build() restful.FilterFunction {
+	if config.logger == nil {
+		config.logger = xlog.JupiterLogger.With(xlog.FieldMod(ModName))
+	}
+	if config.doc == nil {
+		doc, err := openapi3.NewLoader().LoadFromFile(config.SpecFile)
+		if err != nil {
+			config.logger.Panic("load openapi spec", xlog.FieldErr(err), xlog.FieldName(config.SpecFile))
+		}
+		config.doc = doc
+	}
+	if err := config.doc.Validate(context.Background()); err != nil {
+		config.logger.Panic("invalid openapi spec", xlog.FieldErr(err), xlog.FieldName(config.SpecFile))
+	}
+	return config.filter
+}
+
+var routeParamRegexp = regexp.MustCompile(`\{([^}:]+):[^}]*\}`)
+
+func (config *OpenAPIConfig) route(req *restful.Request) *routers.Route {
+	// go-restful 的 {id:[0-9]+} 在文档中写作 {id}
+	path := routeParamRegexp.ReplaceAllString(req.SelectedRoutePath(), "{$1}")
+	path = strings.TrimPrefix(path, strings.TrimSuffix(config.BasePath, "/"))
+	item := config.doc.Paths.Find(path)
+	if item == nil {
+		return nil
+	}
+	op := item.GetOperation(req.Request.Method)
+	if op == nil {
+		return nil
+	}
+	return &routers.Route{
+		Spec:      config.doc,
+		Path:      path,
+		PathItem:  item,
+		Method:    req.Request.Method,
+		Operation: op,
+	}
+}
+
+func (config *OpenAPIConfig) filter(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+	route := config.route(req)
+	if route == nil {
+		chain.ProcessFilter(req, resp)
+		return
+	}
+	input := &openapi3filter.RequestValidationInput{
+		Request:    req.Request,
+		PathParams: req.PathParameters(),
+		Route:      route,
+		Options: &openapi3filter.Options{
+			MultiError: true,
+			// 鉴权由其他filter负责
+			AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+		},
+	}
+	ctx := req.Request.Context()
+	if err := openapi3filter.ValidateRequest(ctx, input); err != nil {
+		WriteError(req, resp, errcode.New(codeOpenAPIInvalid).WithDetails(validationErrors(err)))
+		return
+	}
+	if !config.ValidateResponse {
+		chain.ProcessFilter(req, resp)
+		return
+	}
+
+	rec := recordResponse(resp, func() {
+		chain.ProcessFilter(req, resp)
+	})
+	err := openapi3filter.ValidateResponse(ctx, &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: input,
+		Status:                 rec.StatusCode(),
+		Header:                 rec.Header(),
+		Body:                   ioutil.NopCloser(bytes.NewReader(rec.body.Bytes())),
+		Options:                &openapi3filter.Options{MultiError: true, IncludeResponseStatus: true},
+	})
+	if err != nil {
+		config.logger.Warn("response does not match the api document",
+			xlog.FieldMethod(req.Request.Method), xlog.String("path", route.Path), xlog.FieldErr(err))
+	}
+	rec.flushTo(resp.ResponseWriter)
+}
+
+// validationErrors flattens the errors of openapi3filter into located messages
+func validationErrors(err error) []ValidationError {
+	var out []ValidationError
+	var walk func(err error, location string)
+	walk = func(err error, location string) {
+		switch e := err.(type) {
+		case openapi3.MultiError:
+			for _, err := range e {
+				walk(err, location)
+			}
+		case *openapi3filter.RequestError:
+			switch {
+			case e.Parameter != nil:
+				location = "/" + e.Parameter.In + "/" + jsonPointerEscape(e.Parameter.Name)
+			case e.RequestBody != nil:
+				location = "/body"
+			}
+			if e.Err == nil {
+				out = append(out, ValidationError{Location: location, Message: e.Reason})
+				return
+			}
+			walk(e.Err, location)
+		case *openapi3.SchemaError:
+			for _, p := range e.JSONPointer() {
+				location += "/" + jsonPointerEscape(p)
+			}
+			out = append(out, ValidationError{Location: location, Message: e.Reason})
+		default:
+			out = append(out, ValidationError{Location: location, Message: err.Error()})
+		}
+	}
+	walk(err, "")
+	return out
+}
+
+// jsonPointerEscape escapes a JSON pointer reference token, RFC 6901
+func jsonPointerEscape(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
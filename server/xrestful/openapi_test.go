@@ -0,0 +1,86 @@
+package xrestful
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	restful "github.com/emicklei/go-restful/v3"
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+const testSpec = `
+openapi: 3.0.0
+info: {title: test, version: "1"}
+paths:
+  /users/{id}:
+    put:
+      parameters:
+        - {name: id, in: path, required: true, schema: {type: integer}}
+        - {name: dry, in: query, schema: {type: boolean}}
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              type: object
+              required: [name]
+              properties:
+                name: {type: string}
+                tags: {type: array, items: {type: string}}
+      responses:
+        "200": {description: ok}
+`
+
+func TestOpenAPIFilter(t *testing.T) {
+	doc, err := openapi3.NewLoader().LoadFromData([]byte(testSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws := new(restful.WebService)
+	ws.Route(ws.PUT("/users/{id}").
+		Filter(DefaultOpenAPIConfig().WithSpec(doc).Build()).
+		To(func(req *restful.Request, resp *restful.Response) {
+			resp.WriteHeader(http.StatusOK)
+		}))
+	container := restful.NewContainer()
+	container.Add(ws)
+
+	put := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, path, strings.NewReader(body))
+		req.Header.Set(HeaderContentType, MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		container.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := put("/users/1", `{"name":"a"}`); rec.Code != http.StatusOK {
+		t.Fatalf("valid: %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec := put("/users/x?dry=maybe", `{"tags":["a",1]}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid: %d %s", rec.Code, rec.Body.String())
+	}
+	var errs struct {
+		Code    int               `json:"code"`
+		Details []ValidationError `json:"details"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &errs); err != nil {
+		t.Fatal(err)
+	}
+	if errs.Code != codeOpenAPIInvalid {
+		t.Errorf("code %d", errs.Code)
+	}
+	locations := map[string]bool{}
+	for _, e := range errs.Details {
+		locations[e.Location] = true
+	}
+	for _, want := range []string{"/path/id", "/query/dry", "/body/tags/1"} {
+		if !locations[want] {
+			t.Errorf("missing %s in %s", want, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package xrestful
+
+import (
+	"bytes"
+	"net/http"
+
+	restful "github.com/emicklei/go-restful/v3"
+)
+
+// responseRecorder buffers everything a handler writes so that filters can
+// inspect, store or replay the response.
+type responseRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newResponseRecorder() *responseRecorder {
+	return &responseRecorder{header: make(http.Header)}
+}
+
+// Header implements http.ResponseWriter
+func (r *responseRecorder) Header() http.Header {
+	return r.header
+}
+
+// WriteHeader implements http.ResponseWriter, only the first status is kept
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+// Write implements http.ResponseWriter
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.body.Write(b)
+}
+
+// StatusCode returns the recorded status, 200 if nothing was written
+func (r *responseRecorder) StatusCode() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
+
+// flushTo copies the recorded response into w
+func (r *responseRecorder) flushTo(w http.ResponseWriter) {
+	copyHeader(w.Header(), r.header)
+	w.WriteHeader(r.StatusCode())
+	w.Write(r.body.Bytes())
+}
+
+// recordResponse runs fn with resp writing into a recorder instead of the client.
+// The recorded response is NOT sent, callers decide what to do with it.
+func recordResponse(resp *restful.Response, fn func()) *responseRecorder {
+	rec := newResponseRecorder()
+	origin := resp.ResponseWriter
+	resp.ResponseWriter = rec
+	defer func() {
+		resp.ResponseWriter = origin
+	}()
+	fn()
+	return rec
+}
+
+func copyHeader(dst, src http.Header) {
+	for k, vv := range src {
+		dst[k] = append([]string(nil), vv...)
+	}
+}
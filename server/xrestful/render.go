@@ -0,0 +1,360 @@
+package xrestful
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	restful "github.com/emicklei/go-restful/v3"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"gopkg.in/yaml.v2"
+)
+
+// Renderer encodes response entities of one format, see RegisterRenderer.
+// A Renderer that also has a Decode(r io.Reader, v interface{}) error method
+// is used by restful.Request.ReadEntity too.
+type Renderer interface {
+	// ContentType is written as the Content-Type header
+	ContentType() string
+	Render(w io.Writer, v interface{}) error
+}
+
+type decoder interface {
+	Decode(r io.Reader, v interface{}) error
+}
+
+var renderers = struct {
+	sync.RWMutex
+	mimes  []string
+	byMIME map[string]Renderer
+}{byMIME: make(map[string]Renderer)}
+
+// RegisterRenderer add or replace the renderer of mime, it is also registered
+// as the go-restful entity accessor of mime so WriteEntity uses it as well.
+// A JSONRenderer without UseJSONPB keeps the go-restful JSON accessor, which
+// honours restful.PrettyPrintResponses and the pluggable restful.NewEncoder.
+func RegisterRenderer(mime string, r Renderer) {
+	renderers.Lock()
+	if _, ok := renderers.byMIME[mime]; !ok {
+		renderers.mimes = append(renderers.mimes, mime)
+	}
+	renderers.byMIME[mime] = r
+	renderers.Unlock()
+	if jr, ok := r.(*JSONRenderer); ok && mime == MIMEApplicationJSON && !jr.UseJSONPB {
+		restful.RegisterEntityAccessor(mime, restful.NewEntityAccessorJSON(restful.MIME_JSON))
+		return
+	}
+	restful.RegisterEntityAccessor(mime, entityRenderer{r})
+}
+
+func init() {
+	RegisterRenderer(MIMEApplicationJSON, &JSONRenderer{})
+	RegisterRenderer(MIMEApplicationXML, XMLRenderer{})
+	RegisterRenderer(MIMETextXML, XMLRenderer{})
+	RegisterRenderer(MIMEApplicationYAML, YAMLRenderer{})
+	RegisterRenderer(MIMEApplicationProtobuf, ProtobufRenderer{})
+	RegisterRenderer(MIMEApplicationXProtobuf, ProtobufRenderer{})
+	RegisterRenderer(MIMETextCSV, CSVRenderer{})
+	// 路由未声明Produces且请求未指定Accept时使用JSON, 而不是406
+	restful.DefaultResponseContentType(restful.MIME_JSON)
+}
+
+// RendererMIMEs returns the registered mime types in registration order, JSON first.
+// go-restful answers 406 before any filter when Accept does not match the route Produces,
+// so declare them on the WebService or route: ws.Produces(xrestful.RendererMIMEs()...)
+func RendererMIMEs() []string {
+	renderers.RLock()
+	defer renderers.RUnlock()
+	return append([]string(nil), renderers.mimes...)
+}
+
+// Render writes obj with status in the format negotiated from the Accept header.
+// A nil obj writes the status only, 406 is written when no format is acceptable.
+func Render(resp *restful.Response, status int, obj interface{}) error {
+	return resp.WriteHeaderAndEntity(status, obj)
+}
+
+// negotiateMiddleware narrows the Accept header to the best registered renderer,
+// go-restful only negotiates against the Produces of the route
+func negotiateMiddleware() restful.FilterFunction {
+	return func(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+		if mime, ok := negotiate(req.Request.Header.Get(restful.HEADER_Accept)); ok {
+			resp.SetRequestAccepts(mime)
+		}
+		chain.ProcessFilter(req, resp)
+	}
+}
+
+type acceptRange struct {
+	media string
+	q     float64
+}
+
+// negotiate returns the registered mime preferred by accept,
+// false means any format will do or none is registered
+func negotiate(accept string) (string, bool) {
+	if accept == "" {
+		return "", false
+	}
+	var ranges []acceptRange
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		r := acceptRange{media: strings.ToLower(strings.TrimSpace(params[0])), q: 1}
+		for _, p := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+			if len(kv) == 2 && kv[0] == "q" {
+				if q, err := strconv.ParseFloat(kv[1], 64); err == nil {
+					r.q = q
+				}
+			}
+		}
+		if r.q > 0 {
+			ranges = append(ranges, r)
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+
+	renderers.RLock()
+	defer renderers.RUnlock()
+	for _, r := range ranges {
+		if r.media == "*/*" {
+			return "", false
+		}
+		if _, ok := renderers.byMIME[r.media]; ok {
+			return r.media, true
+		}
+		if strings.HasSuffix(r.media, "/*") {
+			for _, mime := range renderers.mimes {
+				if strings.HasPrefix(mime, r.media[:len(r.media)-1]) {
+					return mime, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// entityRenderer adapts a Renderer to restful.EntityReaderWriter
+type entityRenderer struct {
+	Renderer
+}
+
+// Read implements restful.EntityReaderWriter
+func (e entityRenderer) Read(req *restful.Request, v interface{}) error {
+	d, ok := e.Renderer.(decoder)
+	if !ok {
+		return fmt.Errorf("xrestful: %s can not be read", e.ContentType())
+	}
+	return d.Decode(req.Request.Body, v)
+}
+
+// Write implements restful.EntityReaderWriter
+func (e entityRenderer) Write(resp *restful.Response, status int, v interface{}) error {
+	if v == nil {
+		resp.WriteHeader(status)
+		return nil
+	}
+	// 先编码到缓冲区, 编码失败时仍可写入500
+	var buf bytes.Buffer
+	if err := e.Render(&buf, v); err != nil {
+		resp.WriteHeader(StatusInternalServerError)
+		return err
+	}
+	resp.Header().Set(HeaderContentType, e.ContentType())
+	resp.WriteHeader(status)
+	_, err := resp.Write(buf.Bytes())
+	return err
+}
+
+// JSONRenderer renders JSON, proto messages are marshaled with jsonpb when UseJSONPB is set:
+//
+//	xrestful.RegisterRenderer(xrestful.MIMEApplicationJSON, &xrestful.JSONRenderer{UseJSONPB: true})
+type JSONRenderer struct {
+	UseJSONPB bool
+	// 为空时使用 EmitDefaults 的 jsonpb.Marshaler
+	Marshaler *jsonpb.Marshaler
+}
+
+// ContentType ...
+func (r *JSONRenderer) ContentType() string {
+	return MIMEApplicationJSONCharsetUTF8
+}
+
+// Render ...
+func (r *JSONRenderer) Render(w io.Writer, v interface{}) error {
+	if m, ok := v.(proto.Message); ok && r.UseJSONPB {
+		marshaler := r.Marshaler
+		if marshaler == nil {
+			marshaler = &jsonpbMarshaler
+		}
+		return marshaler.Marshal(w, m)
+	}
+	return json.NewEncoder(w).Encode(v)
+}
+
+// Decode ...
+func (r *JSONRenderer) Decode(rd io.Reader, v interface{}) error {
+	if m, ok := v.(proto.Message); ok && r.UseJSONPB {
+		return jsonpb.Unmarshal(rd, m)
+	}
+	d := json.NewDecoder(rd)
+	d.UseNumber()
+	return d.Decode(v)
+}
+
+// XMLRenderer ...
+type XMLRenderer struct{}
+
+// ContentType ...
+func (XMLRenderer) ContentType() string {
+	return MIMEApplicationXML + "; " + charsetUTF8
+}
+
+// Render ...
+func (XMLRenderer) Render(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(v)
+}
+
+// Decode ...
+func (XMLRenderer) Decode(r io.Reader, v interface{}) error {
+	return xml.NewDecoder(r).Decode(v)
+}
+
+// YAMLRenderer ...
+type YAMLRenderer struct{}
+
+// ContentType ...
+func (YAMLRenderer) ContentType() string {
+	return MIMEApplicationYAML + "; " + charsetUTF8
+}
+
+// Render ...
+func (YAMLRenderer) Render(w io.Writer, v interface{}) error {
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// Decode ...
+func (YAMLRenderer) Decode(r io.Reader, v interface{}) error {
+	return yaml.NewDecoder(r).Decode(v)
+}
+
+// ProtobufRenderer renders proto.Message values only
+type ProtobufRenderer struct{}
+
+// ContentType ...
+func (ProtobufRenderer) ContentType() string {
+	return MIMEApplicationXProtobuf
+}
+
+// Render ...
+func (ProtobufRenderer) Render(w io.Writer, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("xrestful: %T is not a proto.Message", v)
+	}
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// Decode ...
+func (ProtobufRenderer) Decode(r io.Reader, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("xrestful: %T is not a proto.Message", v)
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(b, m)
+}
+
+// CSVRenderer renders [][]string, or a slice of structs with a header row.
+// Column names come from the csv tag then the field name, `csv:"-"` skips a field.
+type CSVRenderer struct{}
+
+// ContentType ...
+func (CSVRenderer) ContentType() string {
+	return MIMETextCSV + "; " + charsetUTF8
+}
+
+// Render ...
+func (CSVRenderer) Render(w io.Writer, v interface{}) error {
+	cw := csv.NewWriter(w)
+	if records, ok := v.([][]string); ok {
+		return cw.WriteAll(records)
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return fmt.Errorf("xrestful: csv can not render %T", v)
+	}
+	elem := rv.Type().Elem()
+	for elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	if elem.Kind() != reflect.Struct {
+		return fmt.Errorf("xrestful: csv can not render %T", v)
+	}
+	var (
+		fields []int
+		header []string
+	)
+	for i := 0; i < elem.NumField(); i++ {
+		f := elem.Field(i)
+		name := f.Tag.Get("csv")
+		if f.PkgPath != "" || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, i)
+		header = append(header, name)
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	record := make([]string, len(fields))
+	for i := 0; i < rv.Len(); i++ {
+		item := rv.Index(i)
+		for item.Kind() == reflect.Ptr {
+			item = item.Elem()
+		}
+		if !item.IsValid() {
+			continue
+		}
+		for j, idx := range fields {
+			record[j] = fmt.Sprint(item.Field(idx).Interface())
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,33 @@
+//go:build !nomsgpack
+// +build !nomsgpack
+
+package xrestful
+
+import (
+	"io"
+
+	"github.com/ugorji/go/codec"
+)
+
+func init() {
+	RegisterRenderer(MIMEApplicationMsgPack, MsgPackRenderer{})
+	RegisterRenderer(MIMEApplicationMsgPack2, MsgPackRenderer{})
+}
+
+// MsgPackRenderer ...
+type MsgPackRenderer struct{}
+
+// ContentType ...
+func (MsgPackRenderer) ContentType() string {
+	return MIMEApplicationMsgPack
+}
+
+// Render ...
+func (MsgPackRenderer) Render(w io.Writer, v interface{}) error {
+	return codec.NewEncoder(w, new(codec.MsgpackHandle)).Encode(v)
+}
+
+// Decode ...
+func (MsgPackRenderer) Decode(r io.Reader, v interface{}) error {
+	return codec.NewDecoder(r, new(codec.MsgpackHandle)).Decode(v)
+}
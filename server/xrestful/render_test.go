@@ -0,0 +1,74 @@
+package xrestful
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	restful "github.com/emicklei/go-restful/v3"
+)
+
+func TestNegotiate(t *testing.T) {
+	cases := map[string]string{
+		"":                                  "",
+		"*/*":                               "",
+		"application/x-yaml":                MIMEApplicationYAML,
+		"text/html, application/xml;q=0.9":  MIMEApplicationXML,
+		"application/json;q=0.5, text/csv":  MIMETextCSV,
+		"text/html, application/*;q=0.1":    MIMEApplicationJSON,
+		"application/msgpack;q=0, text/csv": MIMETextCSV,
+	}
+	for accept, want := range cases {
+		if got, _ := negotiate(accept); got != want {
+			t.Errorf("negotiate(%q) = %q, want %q", accept, got, want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	type item struct {
+		ID   int    `json:"id" yaml:"id" csv:"id"`
+		Name string `json:"name" yaml:"name" csv:"name"`
+		Note string `csv:"-"`
+	}
+	ws := new(restful.WebService).Produces(RendererMIMEs()...)
+	ws.Route(ws.GET("/items").To(func(req *restful.Request, resp *restful.Response) {
+		Render(resp, http.StatusOK, []item{{ID: 1, Name: "a"}})
+	}))
+	container := restful.NewContainer()
+	container.Filter(negotiateMiddleware())
+	container.Add(ws)
+
+	cases := []struct {
+		accept, contentType, body string
+	}{
+		{"application/x-yaml", "application/x-yaml; charset=utf-8", "- id: 1\n  name: a\n  note: \"\"\n"},
+		{"text/csv", "text/csv; charset=utf-8", "id,name\n1,a\n"},
+		// 未启用jsonpb时JSON由go-restful输出, 默认缩进
+		{"", "application/json", "[\n {\n  \"id\": 1,\n  \"name\": \"a\",\n  \"Note\": \"\"\n }\n]"},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/items", nil)
+		req.Header.Set("Accept", c.accept)
+		rec := httptest.NewRecorder()
+		container.ServeHTTP(rec, req)
+		if rec.Header().Get(HeaderContentType) != c.contentType || rec.Body.String() != c.body {
+			t.Errorf("accept %q: %q %q", c.accept, rec.Header().Get(HeaderContentType), rec.Body.String())
+		}
+	}
+
+	// 编码失败时响应头尚未写出
+	ws.Route(ws.GET("/bad").To(func(req *restful.Request, resp *restful.Response) {
+		if err := Render(resp, http.StatusOK, map[string]interface{}{"a": 1}); err == nil {
+			t.Error("want encode error")
+		}
+	}))
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/bad", nil)
+	// xml 不支持 map
+	req.Header.Set("Accept", MIMEApplicationXML)
+	container.ServeHTTP(rec, req)
+	if rec.Code != http.StatusInternalServerError || rec.Body.Len() != 0 {
+		t.Errorf("bad: %d %q", rec.Code, rec.Body.String())
+	}
+}
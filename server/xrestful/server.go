@@ -9,39 +9,63 @@ import (
 	restful "github.com/emicklei/go-restful/v3"
 	"net"
 	"net/http"
+	"path/filepath"
 )
 
 // Server ...
 type Server struct {
-	Server   *http.Server
-	config   *Config
-	listener net.Listener
+	Server    *http.Server
+	config    *Config
+	listener  net.Listener
+	container *restful.Container
+	hosts     virtualHosts
 }
 
 func newServer(config *Config) *Server {
-	listener, err := net.Listen("tcp", config.Address())
-	if err != nil {
-		config.logger.Panic("new go-restful server err", xlog.FieldErrKind(ecode.ErrKindListenErr), xlog.FieldErr(err))
+	listener := config.listener
+	if listener == nil {
+		var err error
+		listener, err = config.listen()
+		if err != nil {
+			config.logger.Panic("new go-restful server err", xlog.FieldErrKind(ecode.ErrKindListenErr), xlog.FieldErr(err))
+		}
+	}
+	if addr, ok := listener.Addr().(*net.TCPAddr); ok {
+		config.Port = addr.Port
+	}
+	container := config.container
+	if container == nil {
+		container = restful.DefaultContainer
 	}
-	config.Port = listener.Addr().(*net.TCPAddr).Port
-	return &Server{
+	s := &Server{
 		config:    config,
 		listener:  listener,
+		container: container,
 	}
+	s.Server = &http.Server{
+		Addr:    config.Address(),
+		Handler: http.HandlerFunc(s.serveHTTP),
+	}
+	return s
+}
+
+// Container returns the container serving the routes
+func (s *Server) Container() *restful.Container {
+	return s.container
+}
+
+// Add adds a WebService to the container
+func (s *Server) Add(ws *restful.WebService) *Server {
+	s.container.Add(ws)
+	return s
 }
 
 // Serve implements server.Server interface.
 func (s *Server) Serve() error {
 
-	for _, ws := range restful.DefaultContainer.RegisteredWebServices() {
-		for _, route := range ws.Routes() {
-			s.config.logger.Info("add route", xlog.FieldMethod(route.Method), xlog.String("path", route.Path))
-		}
-
-	}
-	s.Server = &http.Server{
-		Addr:    s.config.Address(),
-		Handler: restful.DefaultContainer,
+	s.logRoutes("", s.container)
+	for _, host := range s.hosts.list() {
+		s.logRoutes(host.pattern, host.container)
 	}
 	err := s.Server.Serve(s.listener)
 	if err == http.ErrServerClosed {
@@ -52,6 +76,21 @@ func (s *Server) Serve() error {
 	return err
 }
 
+func (s *Server) logRoutes(host string, container *restful.Container) {
+	for _, ws := range container.RegisteredWebServices() {
+		for _, route := range ws.Routes() {
+			fields := []xlog.Field{xlog.FieldMethod(route.Method), xlog.String("path", route.Path)}
+			if host != "" {
+				fields = append(fields, xlog.String("host", host))
+			}
+			if versions := routeVersions(route); versions != "" {
+				fields = append(fields, xlog.String("version", versions))
+			}
+			s.config.logger.Info("add route", fields...)
+		}
+	}
+}
+
 // Stop implements server.Server interface
 // it will terminate go-restful server immediately
 func (s *Server) Stop() error {
@@ -67,16 +106,24 @@ func (s *Server) GracefulStop(ctx context.Context) error {
 // Info returns server info, used by governor and consumer balancer
 // TODO(gorexlv): implements government protocol with juno
 func (s *Server) Info() *server.ServiceInfo {
+	scheme := "http"
 	serviceAddr := s.listener.Addr().String()
+	// unix socket 只能本机访问, 地址为socket文件的绝对路径
+	if addr, ok := s.listener.Addr().(*net.UnixAddr); ok {
+		scheme = "http+unix"
+		if abs, err := filepath.Abs(addr.Name); err == nil {
+			serviceAddr = abs
+		}
+	}
 	if s.config.ServiceAddress != "" {
 		serviceAddr = s.config.ServiceAddress
 	}
 
-	info := server.ApplyOptions(
-		server.WithScheme("http"),
+	info := server.ApplyOptions(append([]server.Option{
+		server.WithScheme(scheme),
 		server.WithAddress(serviceAddr),
 		server.WithKind(constant.ServiceProvider),
-	)
+	}, s.config.serviceOptions()...)...)
 	// info.Name = info.Name + "." + ModName
 	return &info
 }
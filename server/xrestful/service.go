@@ -0,0 +1,63 @@
+package xrestful
+
+import (
+	"context"
+	"time"
+
+	"github.com/douyu/jupiter/pkg/registry"
+	"github.com/douyu/jupiter/pkg/server"
+	"github.com/douyu/jupiter/pkg/xlog"
+)
+
+// MetadataServiceVersion is the registry metadata key of Config.Version
+const MetadataServiceVersion = "version"
+
+// WithRegistry registers the service info again in reg when the registry fields
+// of the config change, so that a node is drained by setting enable to false.
+// Pass the registry given to jupiter.Application.SetRegistry.
+func (config *Config) WithRegistry(reg registry.Registry) *Config {
+	config.registry = reg
+	return config
+}
+
+// serviceOptions converts the registry fields of the config into ServiceInfo options
+func (config *Config) serviceOptions() []server.Option {
+	config.mu.Lock()
+	defer config.mu.Unlock()
+	weight, enable := config.Weight, config.Enable
+	region, zone, deployment := config.Region, config.Zone, config.Deployment
+	options := []server.Option{func(info *server.ServiceInfo) {
+		info.Weight = weight
+		info.Enable = enable
+		info.Deployment = deployment
+		if region != "" {
+			info.Region = region
+		}
+		if zone != "" {
+			info.Zone = zone
+		}
+	}}
+	for key, value := range config.Labels {
+		options = append(options, server.WithMetaData(key, value))
+	}
+	if config.Version != "" {
+		options = append(options, server.WithMetaData(MetadataServiceVersion, config.Version))
+	}
+	return options
+}
+
+// register updates the service info in the registry after a config change
+func (config *Config) register() {
+	if config.registry == nil || config.server == nil {
+		return
+	}
+	info := config.server.Info()
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := config.registry.RegisterService(ctx, info); err != nil {
+		config.logger.Error("update service info", xlog.FieldErr(err), xlog.FieldAddr(info.Label()))
+		return
+	}
+	config.logger.Info("update service info", xlog.FieldAddr(info.Label()),
+		xlog.Any("weight", info.Weight), xlog.Any("enable", info.Enable))
+}
@@ -0,0 +1,58 @@
+package xrestful
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/douyu/jupiter/pkg/conf"
+	"github.com/douyu/jupiter/pkg/registry"
+	"github.com/douyu/jupiter/pkg/server"
+	restful "github.com/emicklei/go-restful/v3"
+)
+
+type fakeRegistry struct {
+	registry.Nop
+	infos []*server.ServiceInfo
+}
+
+func (r *fakeRegistry) RegisterService(ctx context.Context, info *server.ServiceInfo) error {
+	r.infos = append(r.infos, info)
+	return nil
+}
+
+func TestServiceInfo(t *testing.T) {
+	reg := &fakeRegistry{}
+	config := DefaultConfig().WithHost("127.0.0.1").WithPort(0).WithContainer(restful.NewContainer()).WithRegistry(reg)
+	config.Zone = "zone-a"
+	config.Version = "1.2.0"
+	config.Labels = map[string]string{"tier": "web"}
+	s := config.Build()
+	defer s.Stop()
+
+	info := s.Info()
+	if info.Weight != 100 || !info.Enable || info.Zone != "zone-a" ||
+		info.Metadata[MetadataServiceVersion] != "1.2.0" || info.Metadata["tier"] != "web" {
+		t.Fatalf("info = %+v", info)
+	}
+
+	c := conf.New()
+	err := c.LoadFromReader(strings.NewReader(`{"server": {"zone": "zone-a", "version": "1.2.0", "labels": {"tier": "web"}, "enable": false, "weight": 10}}`), json.Unmarshal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.reload(c, "server")
+	if len(reg.infos) != 1 {
+		t.Fatalf("registered %d times", len(reg.infos))
+	}
+	if info := reg.infos[0]; info.Enable || info.Weight != 10 || info.Address != s.Info().Address {
+		t.Fatalf("registered info = %+v", info)
+	}
+
+	// 未改变注册字段时不重新注册
+	config.reload(c, "server")
+	if len(reg.infos) != 1 {
+		t.Fatalf("registered %d times", len(reg.infos))
+	}
+}
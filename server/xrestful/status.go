@@ -2,7 +2,6 @@ package xrestful
 
 import (
 	"encoding/json"
-	"fmt"
 	"strconv"
 	"strings"
 
@@ -102,6 +101,3 @@ func statusFromString(s string) (*statusErr, bool) {
 		},
 	}, true
 }
-func createStatusErr(code uint32, msg string) string {
-	return fmt.Sprintf("%d:%s", code, msg)
-}
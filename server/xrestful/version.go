@@ -0,0 +1,245 @@
+package xrestful
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/douyu/jupiter/pkg/metric"
+	restful "github.com/emicklei/go-restful/v3"
+	"github.com/system18188/jupiter-plugin/server/xrestful/errcode"
+)
+
+const (
+	// HeaderAcceptVersion 请求指定的API版本, 如 2 或 v2
+	HeaderAcceptVersion = "Accept-Version"
+	// HeaderAPIVersion 响应实际使用的API版本
+	HeaderAPIVersion = "Api-Version"
+
+	// MetadataVersion route metadata key of the version served by a route
+	MetadataVersion = "xrestful.version"
+	// MetadataVersions route metadata key of the versions dispatched by an unversioned route
+	MetadataVersions = "xrestful.versions"
+)
+
+var (
+	apiVersionCounter = metric.CounterVecOpts{
+		Namespace: metric.DefaultNamespace,
+		Name:      "server_api_version_total",
+		Labels:    []string{"type", "method", "path", "version"},
+	}.Build()
+
+	vendorMediaType = regexp.MustCompile(`^application/vnd\.([\w.-]+?)\.v(\d+)(?:\+(\w+))?$`)
+	vendorSuffixes  = map[string]string{
+		"":         MIMEApplicationJSON,
+		"json":     MIMEApplicationJSON,
+		"xml":      MIMEApplicationXML,
+		"yaml":     MIMEApplicationYAML,
+		"msgpack":  MIMEApplicationMsgPack,
+		"protobuf": MIMEApplicationXProtobuf,
+	}
+)
+
+// Deprecation of an API version, announced by the Deprecation, Sunset and Link headers
+type Deprecation struct {
+	// 弃用时间, 为零时 Deprecation 头为 true
+	Date time.Time
+	// 下线时间
+	Sunset time.Time
+	// 迁移说明文档
+	Link string
+}
+
+// Versioning registers a route in several versions. For each version n the
+// route is served under /v{n} in front of its path, the unprefixed path picks
+// the version from the Accept-Version header, a vendor media type such as
+// application/vnd.x.v2+json, or falls back to Default.
+type Versioning struct {
+	// 未指定版本时使用的版本, 0表示最新版本
+	Default int
+	// vendor 媒体类型名称, 如 x 对应 application/vnd.x.v2+json, 为空时不支持通过媒体类型指定版本
+	Vendor string
+	// 已弃用的版本
+	Deprecated map[int]Deprecation
+}
+
+// NewVersioning ...
+func NewVersioning() *Versioning {
+	return &Versioning{
+		Deprecated: make(map[int]Deprecation),
+	}
+}
+
+// WithDefault ...
+func (v *Versioning) WithDefault(version int) *Versioning {
+	v.Default = version
+	return v
+}
+
+// WithVendor ...
+func (v *Versioning) WithVendor(vendor string) *Versioning {
+	v.Vendor = vendor
+	return v
+}
+
+// Deprecate marks version as deprecated, its routes are also flagged in the api docs
+func (v *Versioning) Deprecate(version int, d Deprecation) *Versioning {
+	if v.Deprecated == nil {
+		v.Deprecated = make(map[int]Deprecation)
+	}
+	v.Deprecated[version] = d
+	return v
+}
+
+// Route registers path on ws for every version of handlers, method is one of
+// ws.GET, ws.POST... and options document the route, e.g.
+//
+//	versioning.Route(ws, ws.GET, "/users/{id}", map[int]restful.RouteFunction{1: getUserV1, 2: getUserV2},
+//		func(b *restful.RouteBuilder) { b.Param(ws.PathParameter("id", "user id")) })
+func (v *Versioning) Route(ws *restful.WebService, method func(subPath string) *restful.RouteBuilder, path string, handlers map[int]restful.RouteFunction, options ...func(*restful.RouteBuilder)) *Versioning {
+	versions := make([]int, 0, len(handlers))
+	for version := range handlers {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+
+	for _, version := range versions {
+		b := method("/v"+strconv.Itoa(version)+path).
+			To(v.serve(version, handlers[version])).
+			Produces(RendererMIMEs()...).
+			Metadata(MetadataVersion, version)
+		if _, ok := v.Deprecated[version]; ok {
+			b.Deprecate()
+		}
+		for _, option := range options {
+			option(b)
+		}
+		ws.Route(b)
+	}
+
+	b := method(path).
+		To(v.dispatch(versions, handlers)).
+		Produces(append(RendererMIMEs(), v.mediaTypes(versions)...)...).
+		Metadata(MetadataVersions, versions)
+	for _, option := range options {
+		option(b)
+	}
+	ws.Route(b)
+	return v
+}
+
+// mediaTypes are the vendor json media types of versions, the route must produce
+// them or go-restful answers 406 before dispatching
+func (v *Versioning) mediaTypes(versions []int) []string {
+	if v.Vendor == "" {
+		return nil
+	}
+	mimes := make([]string, 0, len(versions))
+	for _, version := range versions {
+		mimes = append(mimes, fmt.Sprintf("application/vnd.%s.v%d+json", strings.ToLower(v.Vendor), version))
+	}
+	return mimes
+}
+
+func (v *Versioning) dispatch(versions []int, handlers map[int]restful.RouteFunction) restful.RouteFunction {
+	fallback := v.Default
+	if fallback == 0 && len(versions) > 0 {
+		fallback = versions[len(versions)-1]
+	}
+	serves := make(map[int]restful.RouteFunction, len(handlers))
+	for version, handler := range handlers {
+		serves[version] = v.serve(version, handler)
+	}
+	return func(req *restful.Request, resp *restful.Response) {
+		version, mime, err := v.requested(req.Request)
+		if err != nil {
+			WriteError(req, resp, err)
+			return
+		}
+		if version == 0 {
+			version = fallback
+		}
+		serve, ok := serves[version]
+		if !ok {
+			WriteError(req, resp, errcode.New(codeVersionUnsupported, version))
+			return
+		}
+		if mime != "" {
+			resp.SetRequestAccepts(mime)
+		}
+		serve(req, resp)
+	}
+}
+
+// requested returns the version asked by the Accept-Version header or a vendor
+// media type in Accept, and the plain mime type the vendor type stands for
+func (v *Versioning) requested(r *http.Request) (int, string, error) {
+	if h := r.Header.Get(HeaderAcceptVersion); h != "" {
+		version, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(h)), "v"))
+		if err != nil || version <= 0 {
+			return 0, "", errcode.New(codeVersionInvalid, h)
+		}
+		return version, "", nil
+	}
+	if v.Vendor == "" {
+		return 0, "", nil
+	}
+	for _, part := range strings.Split(r.Header.Get(restful.HEADER_Accept), ",") {
+		media := strings.ToLower(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]))
+		m := vendorMediaType.FindStringSubmatch(media)
+		if m == nil || m[1] != strings.ToLower(v.Vendor) {
+			continue
+		}
+		version, _ := strconv.Atoi(m[2])
+		mime, ok := vendorSuffixes[m[3]]
+		if !ok {
+			mime = MIMEApplicationJSON
+		}
+		return version, mime, nil
+	}
+	return 0, "", nil
+}
+
+// serve writes the version headers then calls handler
+func (v *Versioning) serve(version int, handler restful.RouteFunction) restful.RouteFunction {
+	label := "v" + strconv.Itoa(version)
+	d, deprecated := v.Deprecated[version]
+	return func(req *restful.Request, resp *restful.Response) {
+		header := resp.Header()
+		header.Set(HeaderAPIVersion, strconv.Itoa(version))
+		if deprecated {
+			if d.Date.IsZero() {
+				header.Set("Deprecation", "true")
+			} else {
+				header.Set("Deprecation", d.Date.UTC().Format(http.TimeFormat))
+			}
+			if !d.Sunset.IsZero() {
+				header.Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+			}
+			if d.Link != "" {
+				header.Add("Link", "<"+d.Link+`>; rel="deprecation"`)
+			}
+		}
+		apiVersionCounter.Inc(metric.TypeHTTP, req.Request.Method, req.SelectedRoutePath(), label)
+		handler(req, resp)
+	}
+}
+
+// routeVersions describes the versions of a route for the route listing
+func routeVersions(route restful.Route) string {
+	if version, ok := route.Metadata[MetadataVersion].(int); ok {
+		return "v" + strconv.Itoa(version)
+	}
+	if versions, ok := route.Metadata[MetadataVersions].([]int); ok {
+		labels := make([]string, 0, len(versions))
+		for _, version := range versions {
+			labels = append(labels, "v"+strconv.Itoa(version))
+		}
+		return strings.Join(labels, ",")
+	}
+	return ""
+}
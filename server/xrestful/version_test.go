@@ -0,0 +1,64 @@
+package xrestful
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	restful "github.com/emicklei/go-restful/v3"
+)
+
+func TestVersioning(t *testing.T) {
+	handler := func(name string) restful.RouteFunction {
+		return func(req *restful.Request, resp *restful.Response) {
+			Render(resp, http.StatusOK, map[string]string{"name": name, "id": req.PathParameter("id")})
+		}
+	}
+	sunset := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	ws := new(restful.WebService)
+	NewVersioning().WithVendor("x").Deprecate(1, Deprecation{Sunset: sunset}).
+		Route(ws, ws.GET, "/users/{id}", map[int]restful.RouteFunction{1: handler("v1"), 2: handler("v2")})
+	container := restful.NewContainer()
+	container.Add(ws)
+
+	cases := []struct {
+		path, header, value string
+		version             string
+		body                string
+	}{
+		{"/v1/users/7", "", "", "1", `{"id":"7","name":"v1"}`},
+		{"/users/7", "", "", "2", `{"id":"7","name":"v2"}`},
+		{"/users/7", HeaderAcceptVersion, "v1", "1", `{"id":"7","name":"v1"}`},
+		{"/users/7", "Accept", "application/vnd.x.v1+json", "1", `{"id":"7","name":"v1"}`},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, c.path, nil)
+		if c.header != "" {
+			req.Header.Set(c.header, c.value)
+		}
+		rec := httptest.NewRecorder()
+		container.ServeHTTP(rec, req)
+		var body bytes.Buffer
+		json.Compact(&body, rec.Body.Bytes())
+		if rec.Code != http.StatusOK || rec.Header().Get(HeaderAPIVersion) != c.version || body.String() != c.body {
+			t.Errorf("%s %s=%s: %d %s %q", c.path, c.header, c.value, rec.Code, rec.Header().Get(HeaderAPIVersion), rec.Body.String())
+			continue
+		}
+		deprecated := c.version == "1"
+		if (rec.Header().Get("Deprecation") == "true") != deprecated ||
+			(deprecated && rec.Header().Get("Sunset") != sunset.Format(http.TimeFormat)) {
+			t.Errorf("%s %s=%s: deprecation headers %v", c.path, c.header, c.value, rec.Header())
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/users/7", nil)
+	req.Header.Set(HeaderAcceptVersion, "3")
+	rec := httptest.NewRecorder()
+	container.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("unsupported version: %d", rec.Code)
+	}
+}
@@ -0,0 +1,141 @@
+package xrestful
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/douyu/jupiter/pkg/xlog"
+	restful "github.com/emicklei/go-restful/v3"
+)
+
+type hostMatchKey struct{}
+
+// HostMatch is the virtual host that served a request
+type HostMatch struct {
+	// 注册时的模式, 如 *.tenant.example.com
+	Pattern string
+	// 请求的Host, 小写且不含端口
+	Host string
+	// 通配符匹配到的标签, 如 a.tenant.example.com 中的 a, 用于识别租户
+	Wildcard string
+}
+
+// MatchedHost returns the virtual host of req, false when it was served by the default container
+func MatchedHost(req *restful.Request) (HostMatch, bool) {
+	match, ok := req.Request.Context().Value(hostMatchKey{}).(HostMatch)
+	return match, ok
+}
+
+type virtualHost struct {
+	pattern   string
+	suffix    string
+	container *restful.Container
+}
+
+// virtualHosts are the containers keyed by Host pattern
+type virtualHosts struct {
+	mu    sync.RWMutex
+	exact map[string]*virtualHost
+	// 按后缀长度降序, 更具体的模式优先
+	wildcards []*virtualHost
+}
+
+func (hosts *virtualHosts) get(pattern string) *virtualHost {
+	if strings.HasPrefix(pattern, "*.") {
+		for _, host := range hosts.wildcards {
+			if host.pattern == pattern {
+				return host
+			}
+		}
+		return nil
+	}
+	return hosts.exact[pattern]
+}
+
+func (hosts *virtualHosts) add(host *virtualHost) {
+	if host.suffix == "" {
+		if hosts.exact == nil {
+			hosts.exact = make(map[string]*virtualHost)
+		}
+		hosts.exact[host.pattern] = host
+		return
+	}
+	hosts.wildcards = append(hosts.wildcards, host)
+	sort.SliceStable(hosts.wildcards, func(i, j int) bool {
+		return len(hosts.wildcards[i].suffix) > len(hosts.wildcards[j].suffix)
+	})
+}
+
+// match returns the host of name, exact patterns win over wildcards
+func (hosts *virtualHosts) match(name string) (*virtualHost, HostMatch, bool) {
+	hosts.mu.RLock()
+	defer hosts.mu.RUnlock()
+	if host, ok := hosts.exact[name]; ok {
+		return host, HostMatch{Pattern: host.pattern, Host: name}, true
+	}
+	for _, host := range hosts.wildcards {
+		label := strings.TrimSuffix(name, host.suffix)
+		// * 只匹配一级标签
+		if label == name || label == "" || strings.Contains(label, ".") {
+			continue
+		}
+		return host, HostMatch{Pattern: host.pattern, Host: name, Wildcard: label}, true
+	}
+	return nil, HostMatch{}, false
+}
+
+func (hosts *virtualHosts) list() []*virtualHost {
+	hosts.mu.RLock()
+	defer hosts.mu.RUnlock()
+	list := make([]*virtualHost, 0, len(hosts.exact)+len(hosts.wildcards))
+	for _, host := range hosts.exact {
+		list = append(list, host)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].pattern < list[j].pattern })
+	return append(list, hosts.wildcards...)
+}
+
+// Host returns the container serving requests whose Host header matches pattern,
+// creating it with the server filters on first use. pattern is a host name such
+// as api.example.com or a wildcard such as *.tenant.example.com, where * matches
+// one label. Requests of unknown hosts are served by the default container.
+func (s *Server) Host(pattern string) *restful.Container {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	if pattern == "" || pattern == "*" {
+		s.config.logger.Panic("invalid virtual host pattern", xlog.String("host", pattern))
+	}
+	s.hosts.mu.Lock()
+	defer s.hosts.mu.Unlock()
+	if host := s.hosts.get(pattern); host != nil {
+		return host.container
+	}
+	host := &virtualHost{pattern: pattern, container: restful.NewContainer()}
+	if strings.HasPrefix(pattern, "*.") {
+		host.suffix = pattern[1:]
+	}
+	s.config.installFilters(host.container)
+	s.hosts.add(host)
+	return host.container
+}
+
+// serveHTTP dispatches to the container of the request host
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	host, match, ok := s.hosts.match(hostName(r.Host))
+	if !ok {
+		s.container.ServeHTTP(w, r)
+		return
+	}
+	host.container.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), hostMatchKey{}, match)))
+}
+
+// hostName strips the port and trailing dot of a Host header
+func hostName(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
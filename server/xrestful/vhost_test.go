@@ -0,0 +1,55 @@
+package xrestful
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	restful "github.com/emicklei/go-restful/v3"
+)
+
+func TestVirtualHosts(t *testing.T) {
+	config := DefaultConfig().WithHost("127.0.0.1").WithPort(0).WithContainer(restful.NewContainer())
+	config.DisableTrace = true
+	s := config.Build()
+	defer s.Stop()
+
+	handler := func(name string) restful.RouteFunction {
+		return func(req *restful.Request, resp *restful.Response) {
+			match, _ := MatchedHost(req)
+			resp.WriteErrorString(http.StatusOK, name+":"+match.Pattern+":"+match.Wildcard)
+		}
+	}
+	route := func(container *restful.Container, name string) {
+		ws := new(restful.WebService)
+		ws.Route(ws.GET("/").To(handler(name)))
+		container.Add(ws)
+	}
+	route(s.Container(), "default")
+	route(s.Host("api.example.com"), "api")
+	route(s.Host("*.tenant.example.com"), "tenant")
+	route(s.Host("*.example.com"), "wildcard")
+	if s.Host("API.example.com") != s.Host("api.example.com") {
+		t.Fatal("host container not reused")
+	}
+
+	cases := []struct {
+		host, want string
+	}{
+		{"api.example.com:8080", "api:api.example.com:"},
+		{"API.Example.com.", "api:api.example.com:"},
+		{"a.tenant.example.com", "tenant:*.tenant.example.com:a"},
+		{"tenant.example.com", "wildcard:*.example.com:tenant"},
+		{"x.a.tenant.example.com", "default::"},
+		{"other.org", "default::"},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Host = c.host
+		s.Server.Handler.ServeHTTP(rec, r)
+		if rec.Code != http.StatusOK || rec.Body.String() != c.want {
+			t.Errorf("%s: %d %q, want %q", c.host, rec.Code, rec.Body.String(), c.want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package xrestfultest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/system18188/jupiter-plugin/server/xrestful"
+)
+
+// RequestBuilder builds a request with a fluent api, Do sends it
+type RequestBuilder struct {
+	server  *Server
+	method  string
+	path    string
+	query   url.Values
+	header  http.Header
+	cookies []*http.Cookie
+	body    io.Reader
+	err     error
+}
+
+func newRequestBuilder(server *Server, method, path string) *RequestBuilder {
+	return &RequestBuilder{
+		server: server,
+		method: method,
+		path:   path,
+		query:  make(url.Values),
+		header: make(http.Header),
+	}
+}
+
+// Header sets a request header
+func (b *RequestBuilder) Header(key, value string) *RequestBuilder {
+	b.header.Set(key, value)
+	return b
+}
+
+// Query adds a query parameter
+func (b *RequestBuilder) Query(key, value string) *RequestBuilder {
+	b.query.Add(key, value)
+	return b
+}
+
+// Cookie adds a cookie to the request
+func (b *RequestBuilder) Cookie(name, value string) *RequestBuilder {
+	b.cookies = append(b.cookies, &http.Cookie{Name: name, Value: value})
+	return b
+}
+
+// Body sets a raw body with its content type
+func (b *RequestBuilder) Body(contentType string, body []byte) *RequestBuilder {
+	b.header.Set(xrestful.HeaderContentType, contentType)
+	b.body = bytes.NewReader(body)
+	return b
+}
+
+// JSON encodes v as the json body
+func (b *RequestBuilder) JSON(v interface{}) *RequestBuilder {
+	body, err := json.Marshal(v)
+	if err != nil {
+		b.err = err
+		return b
+	}
+	return b.Body(xrestful.MIMEApplicationJSON, body)
+}
+
+// Form sets an url encoded form body
+func (b *RequestBuilder) Form(values url.Values) *RequestBuilder {
+	return b.Body("application/x-www-form-urlencoded", []byte(values.Encode()))
+}
+
+// Do sends the request, the test fails if it cannot be sent
+func (b *RequestBuilder) Do() *Response {
+	tb := b.server.tb
+	tb.Helper()
+	if b.err != nil {
+		tb.Fatalf("xrestfultest: build %s %s: %v", b.method, b.path, b.err)
+	}
+	target := BaseURL + b.path
+	if len(b.query) > 0 {
+		sep := "?"
+		if strings.Contains(target, "?") {
+			sep = "&"
+		}
+		target += sep + b.query.Encode()
+	}
+	req, err := http.NewRequest(b.method, target, b.body)
+	if err != nil {
+		tb.Fatalf("xrestfultest: new request %s %s: %v", b.method, b.path, err)
+	}
+	req.Header = b.header
+	for _, c := range b.cookies {
+		req.AddCookie(c)
+	}
+	resp, err := b.server.client.Do(req)
+	if err != nil {
+		tb.Fatalf("xrestfultest: do %s %s: %v", b.method, b.path, err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		tb.Fatalf("xrestfultest: read %s %s: %v", b.method, b.path, err)
+	}
+	return &Response{Response: resp, tb: tb, body: body}
+}
@@ -0,0 +1,117 @@
+package xrestfultest
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Response is a received response with assertions, a failed assertion fails the test
+type Response struct {
+	*http.Response
+	tb   testing.TB
+	body []byte
+}
+
+// Body returns the response body
+func (r *Response) Body() []byte {
+	return r.body
+}
+
+// DecodeJSON decodes the body into v
+func (r *Response) DecodeJSON(v interface{}) *Response {
+	r.tb.Helper()
+	if err := json.Unmarshal(r.body, v); err != nil {
+		r.tb.Fatalf("xrestfultest: decode json %q: %v", r.body, err)
+	}
+	return r
+}
+
+// AssertStatus checks the status code
+func (r *Response) AssertStatus(code int) *Response {
+	r.tb.Helper()
+	if r.StatusCode != code {
+		r.tb.Errorf("xrestfultest: status = %d, want %d, body %q", r.StatusCode, code, r.body)
+	}
+	return r
+}
+
+// AssertHeader checks a response header
+func (r *Response) AssertHeader(key, value string) *Response {
+	r.tb.Helper()
+	if got := r.Header.Get(key); got != value {
+		r.tb.Errorf("xrestfultest: header %s = %q, want %q", key, got, value)
+	}
+	return r
+}
+
+// AssertBody checks the raw body
+func (r *Response) AssertBody(body string) *Response {
+	r.tb.Helper()
+	if string(r.body) != body {
+		r.tb.Errorf("xrestfultest: body = %q, want %q", r.body, body)
+	}
+	return r
+}
+
+// AssertJSONPath checks the value at a dot separated path of the json body,
+// array elements are addressed by index, e.g. "data.items.0.name".
+// Numbers are compared as float64 after json decoding, so want may be any numeric type.
+func (r *Response) AssertJSONPath(path string, want interface{}) *Response {
+	r.tb.Helper()
+	var doc interface{}
+	if err := json.Unmarshal(r.body, &doc); err != nil {
+		r.tb.Errorf("xrestfultest: decode json %q: %v", r.body, err)
+		return r
+	}
+	got, ok := lookupJSONPath(doc, path)
+	if !ok {
+		r.tb.Errorf("xrestfultest: json path %q not found in %s", path, r.body)
+		return r
+	}
+	if !reflect.DeepEqual(got, normalizeJSON(want)) {
+		r.tb.Errorf("xrestfultest: json path %q = %#v, want %#v", path, got, want)
+	}
+	return r
+}
+
+func lookupJSONPath(doc interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return doc, true
+	}
+	for _, part := range strings.Split(path, ".") {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			v, ok := node[part]
+			if !ok {
+				return nil, false
+			}
+			doc = v
+		case []interface{}:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			doc = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return doc, true
+}
+
+// normalizeJSON converts v to the types produced by json decoding into interface{}
+func normalizeJSON(v interface{}) interface{} {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out interface{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return v
+	}
+	return out
+}
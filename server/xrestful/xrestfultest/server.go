@@ -0,0 +1,86 @@
+package xrestfultest
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"testing"
+
+	restful "github.com/emicklei/go-restful/v3"
+	"github.com/system18188/jupiter-plugin/server/xrestful"
+)
+
+// BaseURL is the url prefix of requests sent to a test Server
+const BaseURL = "http://xrestfultest"
+
+// Server is a xrestful server listening in memory
+type Server struct {
+	*xrestful.Server
+	tb       testing.TB
+	listener *pipeListener
+	client   *http.Client
+}
+
+// NewServer builds config with all its filters on a new container and an
+// in-memory listener, then starts serving. The server is closed when the test ends.
+func NewServer(tb testing.TB, config *xrestful.Config) *Server {
+	tb.Helper()
+	if config == nil {
+		config = xrestful.DefaultConfig()
+	}
+	listener := newPipeListener()
+	s := &Server{
+		Server:   config.WithContainer(restful.NewContainer()).WithListener(listener).Build(),
+		tb:       tb,
+		listener: listener,
+	}
+	jar, _ := cookiejar.New(nil)
+	s.client = &http.Client{
+		Transport: &http.Transport{DialContext: listener.DialContext},
+		Jar:       jar,
+	}
+	go s.Serve()
+	tb.Cleanup(s.Close)
+	return s
+}
+
+// Client returns the http client connected to the server, cookies are kept between requests
+func (s *Server) Client() *http.Client {
+	return s.client
+}
+
+// Close stops the server
+func (s *Server) Close() {
+	s.client.CloseIdleConnections()
+	s.Stop()
+	s.listener.Close()
+}
+
+// Request starts building a request to path
+func (s *Server) Request(method, path string) *RequestBuilder {
+	return newRequestBuilder(s, method, path)
+}
+
+// GET ...
+func (s *Server) GET(path string) *RequestBuilder {
+	return s.Request(http.MethodGet, path)
+}
+
+// POST ...
+func (s *Server) POST(path string) *RequestBuilder {
+	return s.Request(http.MethodPost, path)
+}
+
+// PUT ...
+func (s *Server) PUT(path string) *RequestBuilder {
+	return s.Request(http.MethodPut, path)
+}
+
+// PATCH ...
+func (s *Server) PATCH(path string) *RequestBuilder {
+	return s.Request(http.MethodPatch, path)
+}
+
+// DELETE ...
+func (s *Server) DELETE(path string) *RequestBuilder {
+	return s.Request(http.MethodDelete, path)
+}
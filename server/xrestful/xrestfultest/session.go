@@ -0,0 +1,65 @@
+package xrestfultest
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// SessionStore is an in-memory session store for tests, it satisfies scs.Store
+// so it can be set as SessionManager.Store. Unlike memstore it exposes the
+// stored sessions for assertions.
+type SessionStore struct {
+	mu     sync.Mutex
+	data   map[string][]byte
+	expiry map[string]time.Time
+}
+
+// NewSessionStore ...
+func NewSessionStore() *SessionStore {
+	return &SessionStore{
+		data:   make(map[string][]byte),
+		expiry: make(map[string]time.Time),
+	}
+}
+
+// Find implements scs.Store
+func (s *SessionStore) Find(token string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.data[token]
+	if !ok || time.Now().After(s.expiry[token]) {
+		return nil, false, nil
+	}
+	return b, true, nil
+}
+
+// Commit implements scs.Store
+func (s *SessionStore) Commit(token string, b []byte, expiry time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[token] = b
+	s.expiry[token] = expiry
+	return nil
+}
+
+// Delete implements scs.Store
+func (s *SessionStore) Delete(token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data, token)
+	delete(s.expiry, token)
+	return nil
+}
+
+// Tokens returns the stored session tokens in order
+func (s *SessionStore) Tokens() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokens := make([]string, 0, len(s.data))
+	for token := range s.data {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+	return tokens
+}
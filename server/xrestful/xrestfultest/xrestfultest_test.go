@@ -0,0 +1,60 @@
+package xrestfultest
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	restful "github.com/emicklei/go-restful/v3"
+	"github.com/system18188/jupiter-plugin/server/xrestful"
+)
+
+func TestServer(t *testing.T) {
+	config := xrestful.DefaultConfig()
+	config.DisableTrace = true
+	s := NewServer(t, config)
+
+	ws := new(restful.WebService)
+	ws.Route(ws.POST("/echo").To(func(req *restful.Request, resp *restful.Response) {
+		var body map[string]interface{}
+		if err := req.ReadEntity(&body); err != nil {
+			resp.WriteError(http.StatusBadRequest, err)
+			return
+		}
+		cookie, _ := req.Request.Cookie("sid")
+		resp.AddHeader("X-Sid", cookie.Value)
+		resp.WriteAsJson(map[string]interface{}{
+			"data": map[string]interface{}{"items": []interface{}{body}, "q": req.QueryParameter("q")},
+		})
+	}))
+	s.Add(ws)
+
+	s.POST("/echo").
+		Query("q", "x").
+		Cookie("sid", "abc").
+		JSON(map[string]interface{}{"name": "jupiter", "n": 1}).
+		Do().
+		AssertStatus(http.StatusOK).
+		AssertHeader("X-Sid", "abc").
+		AssertJSONPath("data.items.0.name", "jupiter").
+		AssertJSONPath("data.items.0.n", 1).
+		AssertJSONPath("data.q", "x")
+
+	s.GET("/missing").Do().AssertStatus(http.StatusNotFound)
+}
+
+func TestSessionStore(t *testing.T) {
+	store := NewSessionStore()
+	store.Commit("a", []byte("v"), time.Now().Add(time.Minute))
+	store.Commit("b", []byte("v"), time.Now().Add(-time.Minute))
+	if _, found, _ := store.Find("a"); !found {
+		t.Fatal("a should be found")
+	}
+	if _, found, _ := store.Find("b"); found {
+		t.Fatal("b should be expired")
+	}
+	store.Delete("a")
+	if tokens := store.Tokens(); len(tokens) != 1 || tokens[0] != "b" {
+		t.Fatalf("tokens = %v", tokens)
+	}
+}
@@ -0,0 +1,373 @@
+package dbr
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/system18188/jupiter-plugin/store/dbr/dialect"
+)
+
+// bulkLimit returns the default rows and the max placeholders of a statement of d,
+// 0 placeholders means no limit
+func bulkLimit(d Dialect) (rows, params int) {
+	switch d {
+	case dialect.MySQL, dialect.PostgreSQL:
+		// 协议中参数个数为 uint16
+		return 1000, 65535
+	case dialect.SQLite3:
+		// SQLITE_MAX_VARIABLE_NUMBER 在 3.32 之前默认为 999
+		return 500, 999
+	case dialect.ClickHouse:
+		return 10000, 0
+	}
+	return 1000, 65535
+}
+
+// BatchResult is the result of a batch of BulkInsert
+type BatchResult struct {
+	// 批次在rows中的下标范围 [Start, End)
+	Start int
+	End   int
+	// 受影响的行数, MySQL 的 upsert 中更新的行计为2
+	RowsAffected int64
+	Err          error
+}
+
+// BulkResult is the result of BulkInsert, with InTransaction the rows affected
+// of a rolled back transaction are reported too
+type BulkResult struct {
+	Batches      []BatchResult
+	RowsAffected int64
+}
+
+// Failed returns the failed batches
+func (r *BulkResult) Failed() []BatchResult {
+	var failed []BatchResult
+	for _, batch := range r.Batches {
+		if batch.Err != nil {
+			failed = append(failed, batch)
+		}
+	}
+	return failed
+}
+
+// BulkError is returned by BulkInsert when batches fail, it unwraps to the
+// error of the first failed batch
+type BulkError struct {
+	Failed []BatchResult
+}
+
+func (e *BulkError) Error() string {
+	first := e.Failed[0]
+	return fmt.Sprintf("dbr: bulk insert rows [%d,%d): %v (%d failed batches)", first.Start, first.End, first.Err, len(e.Failed))
+}
+
+// Unwrap returns the error of the first failed batch
+func (e *BulkError) Unwrap() error {
+	return e.Failed[0].Err
+}
+
+// BulkInsertBuilder inserts a slice of structs or maps with as many statements
+// as the dialect limits require
+type BulkInsertBuilder interface {
+	EventReceiver
+	// Columns defaults to the sorted keys of the first map or the sorted db columns of the first struct
+	Columns(column ...string) BulkInsertBuilder
+	// BatchSize sets the max rows of a statement, it is lowered to fit the placeholder limit
+	BatchSize(rows int) BulkInsertBuilder
+	// MaxBytes sets the max size of the interpolated statement, e.g. below max_allowed_packet
+	MaxBytes(n int) BulkInsertBuilder
+	// InTransaction runs the batches in a transaction, or a savepoint inside a transaction
+	InTransaction() BulkInsertBuilder
+	// ContinueOnError runs the remaining batches after a failure, ignored with InTransaction
+	ContinueOnError() BulkInsertBuilder
+	OnConflictMap(constraint string, actions map[string]interface{}) BulkInsertBuilder
+	OnConflict(constraint string) ConflictStmt
+	Exec() (*BulkResult, error)
+	ExecContext(ctx context.Context) (*BulkResult, error)
+}
+
+type bulkInsertBuilder struct {
+	EventReceiver
+	runner
+
+	Dialect Dialect
+	// 事务模式下使用, 见 InTransaction
+	sess *Session
+	tx   *Tx
+
+	table           string
+	rows            reflect.Value
+	column          []string
+	conflict        *conflictStmt
+	batchSize       int
+	maxBytes        int
+	inTransaction   bool
+	continueOnError bool
+	// 结构体类型的字段下标
+	fields map[reflect.Type]map[string][]int
+}
+
+// BulkInsert creates a BulkInsertBuilder for rows, a slice of structs, pointers
+// to structs or maps with string keys
+//
+//	result, err := sess.BulkInsert("user", users).
+//		Columns("name", "email").
+//		OnConflictMap("user_email_key", map[string]interface{}{"name": dbr.Proposed("name")}).
+//		InTransaction().
+//		Exec()
+func (sess *Session) BulkInsert(table string, rows interface{}) BulkInsertBuilder {
+	return &bulkInsertBuilder{
+		runner:        sess,
+		EventReceiver: sess,
+		Dialect:       sess.Dialect,
+		sess:          sess,
+		table:         table,
+		rows:          reflect.Indirect(reflect.ValueOf(rows)),
+	}
+}
+
+// BulkInsert creates a BulkInsertBuilder, see Session.BulkInsert
+func (tx *Tx) BulkInsert(table string, rows interface{}) BulkInsertBuilder {
+	return &bulkInsertBuilder{
+		runner:        tx,
+		EventReceiver: tx,
+		Dialect:       tx.Dialect,
+		tx:            tx,
+		table:         table,
+		rows:          reflect.Indirect(reflect.ValueOf(rows)),
+	}
+}
+
+// Columns ...
+func (b *bulkInsertBuilder) Columns(column ...string) BulkInsertBuilder {
+	b.column = append(b.column, column...)
+	return b
+}
+
+// BatchSize ...
+func (b *bulkInsertBuilder) BatchSize(rows int) BulkInsertBuilder {
+	b.batchSize = rows
+	return b
+}
+
+// MaxBytes ...
+func (b *bulkInsertBuilder) MaxBytes(n int) BulkInsertBuilder {
+	b.maxBytes = n
+	return b
+}
+
+// InTransaction ...
+func (b *bulkInsertBuilder) InTransaction() BulkInsertBuilder {
+	b.inTransaction = true
+	return b
+}
+
+// ContinueOnError ...
+func (b *bulkInsertBuilder) ContinueOnError() BulkInsertBuilder {
+	b.continueOnError = true
+	return b
+}
+
+// OnConflictMap allows to add actions for constraint violation, e.g UPSERT
+func (b *bulkInsertBuilder) OnConflictMap(constraint string, actions map[string]interface{}) BulkInsertBuilder {
+	b.conflict = &conflictStmt{constraint: constraint, actions: actions}
+	return b
+}
+
+// OnConflict creates an empty OnConflict section for the statements, e.g UPSERT
+func (b *bulkInsertBuilder) OnConflict(constraint string) ConflictStmt {
+	b.conflict = &conflictStmt{constraint: constraint, actions: make(map[string]interface{})}
+	return b.conflict
+}
+
+// Exec executes the statements
+func (b *bulkInsertBuilder) Exec() (*BulkResult, error) {
+	return b.ExecContext(b.context())
+}
+
+// ExecContext executes the statements with ctx, it returns a *BulkError if batches fail
+func (b *bulkInsertBuilder) ExecContext(ctx context.Context) (*BulkResult, error) {
+	if b.table == "" {
+		return nil, ErrTableNotSpecified
+	}
+	if b.rows.Kind() != reflect.Slice && b.rows.Kind() != reflect.Array {
+		return nil, ErrInvalidPointer
+	}
+	if b.rows.Len() == 0 {
+		return &BulkResult{}, nil
+	}
+	if len(b.column) == 0 {
+		first, _ := extractOriginal(b.rows.Index(0))
+		switch first.Kind() {
+		case reflect.Map:
+			for _, key := range first.MapKeys() {
+				b.column = append(b.column, key.String())
+			}
+		case reflect.Struct:
+			for column := range structMap(first.Type()) {
+				b.column = append(b.column, column)
+			}
+		}
+		if len(b.column) == 0 {
+			return nil, ErrColumnNotSpecified
+		}
+		sort.Strings(b.column)
+	}
+
+	values := make([][]interface{}, b.rows.Len())
+	b.fields = make(map[reflect.Type]map[string][]int)
+	for i := range values {
+		value, err := b.values(b.rows.Index(i))
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	batches, err := b.split(values)
+	if err != nil {
+		return nil, err
+	}
+
+	if !b.inTransaction {
+		return b.run(ctx, b.runner, b.EventReceiver, values, batches, b.continueOnError)
+	}
+	var result *BulkResult
+	fn := func(tx *Tx) (err error) {
+		result, err = b.run(tx.ctx, tx, tx, values, batches, false)
+		return err
+	}
+	if b.tx != nil {
+		err = b.tx.Transaction(fn)
+	} else {
+		err = b.sess.Transaction(ctx, nil, fn)
+	}
+	return result, err
+}
+
+// values returns the values of the columns of row, missing columns are nil
+func (b *bulkInsertBuilder) values(row reflect.Value) ([]interface{}, error) {
+	v, kind := extractOriginal(row)
+	value := make([]interface{}, len(b.column))
+	switch kind {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, ErrInvalidPointer
+		}
+		for i, column := range b.column {
+			if elem := v.MapIndex(reflect.ValueOf(column).Convert(v.Type().Key())); elem.IsValid() {
+				value[i] = elem.Interface()
+			}
+		}
+	case reflect.Struct:
+		m, ok := b.fields[v.Type()]
+		if !ok {
+			m = structMap(v.Type())
+			b.fields[v.Type()] = m
+		}
+		for i, column := range b.column {
+			if index, ok := m[column]; ok {
+				value[i] = v.FieldByIndex(index).Interface()
+			}
+		}
+	default:
+		return nil, ErrInvalidPointer
+	}
+	return value, nil
+}
+
+// split returns the end of each batch, batches stay within the rows, the
+// placeholders and the bytes limits
+func (b *bulkInsertBuilder) split(values [][]interface{}) ([]int, error) {
+	rows, params := bulkLimit(b.Dialect)
+	if b.batchSize > 0 {
+		rows = b.batchSize
+	}
+	if params > 0 {
+		// ON CONFLICT 的每个动作也占用一个参数
+		perRow := (params - b.conflictLen()) / len(b.column)
+		if perRow < 1 {
+			return nil, ErrPlaceholderCount
+		}
+		if perRow < rows {
+			rows = perRow
+		}
+	}
+
+	var header int
+	var tuple string
+	if b.maxBytes > 0 {
+		buf := NewBuffer()
+		if err := b.stmt(nil).Build(b.Dialect, buf); err != nil {
+			return nil, err
+		}
+		header = len(buf.String())
+		tuple = "(" + strings.Repeat(","+placeholder, len(b.column))[1:] + ")"
+	}
+
+	var ends []int
+	n, size := 0, header
+	for i, value := range values {
+		rowSize := 0
+		if b.maxBytes > 0 {
+			ip := interpolator{Buffer: NewBuffer(), Dialect: b.Dialect}
+			if err := ip.interpolate(tuple, value); err != nil {
+				return nil, err
+			}
+			// 元组之间的 ", "
+			rowSize = len(ip.String()) + 2
+		}
+		if n > 0 && (n >= rows || b.maxBytes > 0 && size+rowSize > b.maxBytes) {
+			ends = append(ends, i)
+			n, size = 0, header
+		}
+		n++
+		size += rowSize
+	}
+	return append(ends, len(values)), nil
+}
+
+func (b *bulkInsertBuilder) conflictLen() int {
+	if b.conflict == nil {
+		return 0
+	}
+	return len(b.conflict.actions)
+}
+
+func (b *bulkInsertBuilder) stmt(values [][]interface{}) *insertStmt {
+	stmt := createInsertStmt(b.table)
+	stmt.Column = b.column
+	stmt.Value = values
+	stmt.Conflict = b.conflict
+	return stmt
+}
+
+func (b *bulkInsertBuilder) run(ctx context.Context, runner runner, log EventReceiver, values [][]interface{}, ends []int, continueOnError bool) (*BulkResult, error) {
+	result := &BulkResult{Batches: make([]BatchResult, 0, len(ends))}
+	var failed []BatchResult
+	start := 0
+	for _, end := range ends {
+		batch := BatchResult{Start: start, End: end}
+		res, err := exec(ctx, runner, log, b.stmt(values[start:end]), b.Dialect)
+		if err == nil {
+			batch.RowsAffected, err = res.RowsAffected()
+		}
+		batch.Err = err
+		result.Batches = append(result.Batches, batch)
+		result.RowsAffected += batch.RowsAffected
+		start = end
+		if err != nil {
+			failed = append(failed, batch)
+			if !continueOnError {
+				break
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return result, &BulkError{Failed: failed}
+	}
+	return result, nil
+}
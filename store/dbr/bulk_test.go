@@ -0,0 +1,119 @@
+package dbr_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/system18188/jupiter-plugin/store/dbr"
+	"github.com/system18188/jupiter-plugin/store/dbr/dbrtest"
+)
+
+type bulkItem struct {
+	ID   int64  `db:"id"`
+	Name string `db:"name"`
+	Note string `db:"note"`
+}
+
+func bulkCount(t *testing.T, sess *dbr.Session) int {
+	t.Helper()
+	var n int
+	if err := sess.Select("COUNT(*)").From("bulk_items").LoadOne(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func batchEnds(result *dbr.BulkResult) []int {
+	ends := make([]int, 0, len(result.Batches))
+	for _, batch := range result.Batches {
+		ends = append(ends, batch.End)
+	}
+	return ends
+}
+
+func TestBulkInsert(t *testing.T) {
+	sess := dbrtest.Session(t, "CREATE TABLE bulk_items (id INTEGER PRIMARY KEY, name TEXT, note TEXT)")
+
+	items := make([]*bulkItem, 5)
+	for i := range items {
+		items[i] = &bulkItem{ID: int64(i + 1), Name: "item"}
+	}
+	result, err := sess.BulkInsert("bulk_items", items).Columns("id", "name").BatchSize(2).Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ends := batchEnds(result); len(ends) != 3 || ends[2] != 5 || result.RowsAffected != 5 {
+		t.Fatalf("batches %v rows %d", ends, result.RowsAffected)
+	}
+
+	// sqlite 每条语句最多999个参数, 每行3个参数
+	rows := make([]map[string]interface{}, 700)
+	for i := range rows {
+		rows[i] = map[string]interface{}{"id": 100 + i, "name": "map", "note": nil}
+	}
+	result, err = sess.BulkInsert("bulk_items", rows).Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ends := batchEnds(result); len(ends) != 3 || ends[0] != 333 || ends[1] != 666 || ends[2] != 700 {
+		t.Fatalf("batches %v", ends)
+	}
+
+	result, err = sess.BulkInsert("bulk_items", rows[:10]).MaxBytes(120).Exec()
+	if err == nil || len(result.Batches) != 1 || result.Batches[0].End >= 10 {
+		t.Fatalf("want a small failed first batch, got %v %v", batchEnds(result), err)
+	}
+
+	// 未指定列时使用结构体的所有列
+	structs := []bulkItem{{ID: 1000, Name: "struct", Note: "n"}}
+	if _, err := sess.BulkInsert("bulk_items", structs).Exec(); err != nil {
+		t.Fatal(err)
+	}
+	var note string
+	if err := sess.Select("note").From("bulk_items").Where("id = ?", 1000).LoadOne(&note); err != nil || note != "n" {
+		t.Fatalf("note %q %v", note, err)
+	}
+
+	if bulkCount(t, sess) != 706 {
+		t.Fatalf("%d rows", bulkCount(t, sess))
+	}
+}
+
+func TestBulkInsertFailures(t *testing.T) {
+	sess := dbrtest.Session(t, "CREATE TABLE bulk_items (id INTEGER PRIMARY KEY, name TEXT, note TEXT)")
+	// 第二批与已有的行冲突
+	if _, err := sess.InsertInto("bulk_items").Pair("id", 3).Exec(); err != nil {
+		t.Fatal(err)
+	}
+	items := make([]bulkItem, 6)
+	for i := range items {
+		items[i] = bulkItem{ID: int64(i + 1)}
+	}
+	bulk := func() dbr.BulkInsertBuilder {
+		return sess.BulkInsert("bulk_items", items).Columns("id").BatchSize(2)
+	}
+
+	result, err := bulk().Exec()
+	var bulkErr *dbr.BulkError
+	if !errors.As(err, &bulkErr) || len(result.Batches) != 2 || bulkErr.Failed[0].Start != 2 {
+		t.Fatalf("batches %v err %v", batchEnds(result), err)
+	}
+
+	if _, err := sess.DeleteFrom("bulk_items").Where("id <> 3").Exec(); err != nil {
+		t.Fatal(err)
+	}
+	result, err = bulk().ContinueOnError().Exec()
+	if err == nil || len(result.Batches) != 3 || len(result.Failed()) != 1 || result.RowsAffected != 4 {
+		t.Fatalf("batches %v err %v", batchEnds(result), err)
+	}
+
+	if _, err := sess.DeleteFrom("bulk_items").Where("id <> 3").Exec(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bulk().InTransaction().Exec(); err == nil {
+		t.Fatal("want error")
+	}
+	if bulkCount(t, sess) != 1 {
+		t.Fatalf("%d rows after rollback", bulkCount(t, sess))
+	}
+}
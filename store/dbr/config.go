@@ -1,6 +1,7 @@
 package dbr
 
 import (
+	"database/sql"
 	"fmt"
 	"github.com/douyu/jupiter/pkg/conf"
 	"github.com/douyu/jupiter/pkg/util/xtime"
@@ -35,25 +36,62 @@ type Config struct {
 	MaxOpenConns int `json:"maxOpenConns" toml:"maxOpenConns"`
 	// 最大空闲连接数
 	MaxIdleConns int `json:"maxIdleConns" toml:"maxIdleConns"`
-	// 连接的最大存活时间
+	// 连接的最大存活时间, 如 "300s", 兼容旧配置小于1s的值按秒处理
 	ConnMaxLifetime time.Duration `json:"connMaxLifetime" toml:"connMaxLifetime"`
 
 	// 记录错误sql时,是否打印包含参数的完整sql语句
 	// select * from aid = ?;
 	// select * from aid = 288016;
 	DetailSQL bool `json:"detailSql" toml:"detailSql"`
+	// 慢查询阈值, 超过该时长的语句以Warn级别记录, 默认500ms, 为0时不记录
+	SlowThreshold time.Duration `json:"slowThreshold" toml:"slowThreshold"`
+	// 敏感字段, 打印完整sql语句时这些字段的值以'***'代替
+	SensitiveColumns []string `json:"sensitiveColumns" toml:"sensitiveColumns"`
+	// 关闭prometheus指标
+	DisableMetric bool `json:"disableMetric" toml:"disableMetric"`
+	// 关闭链路追踪
+	DisableTrace bool `json:"disableTrace" toml:"disableTrace"`
+
+	// 使用服务端预处理语句, 参数不再插值到SQL中, clickhouse不支持
+	Prepare bool `json:"prepare" toml:"prepare"`
+	// 缓存的预处理语句数量上限, 默认256
+	StmtCacheSize int `json:"stmtCacheSize" toml:"stmtCacheSize"`
+
+	// 只读副本, 会话的查询发往副本, 写入、事务和 FOR UPDATE 查询发往主库
+	Replicas []ReplicaConfig `json:"replicas" toml:"replicas"`
+	// 副本负载均衡策略 random roundrobin leastconn, 默认random
+	ReplicaPolicy string `json:"replicaPolicy" toml:"replicaPolicy"`
+	// 副本连续连接错误达到该次数后剔除, 默认3
+	ReplicaMaxFailures int `json:"replicaMaxFailures" toml:"replicaMaxFailures"`
+	// 副本剔除时长, 默认30s
+	ReplicaEjectTime time.Duration `json:"replicaEjectTime" toml:"replicaEjectTime"`
+	// 会话写入后该时间内的查询仍然读主库, 为0时不启用
+	ReadYourWrites time.Duration `json:"readYourWrites" toml:"readYourWrites"`
 
 	logger       *xlog.Logger
 }
 
+// ReplicaConfig 只读副本配置
+type ReplicaConfig struct {
+	// 数据库连接
+	DSN string `json:"dsn" toml:"dsn"`
+	// 权重, 默认1
+	Weight int `json:"weight" toml:"weight"`
+}
+
 // DefaultConfig 返回默认配置
 func DefaultConfig() *Config {
 	return &Config{
-		DSN:             "",
-		MaxIdleConns:    10,
-		MaxOpenConns:    100,
-		ConnMaxLifetime: xtime.Duration("300s"),
-		logger:          xlog.DefaultLogger,
+		DSN:                "",
+		MaxIdleConns:       10,
+		MaxOpenConns:       100,
+		ConnMaxLifetime:    xtime.Duration("300s"),
+		SlowThreshold:      xtime.Duration("500ms"),
+		StmtCacheSize:      256,
+		ReplicaPolicy:      ReplicaRandom,
+		ReplicaMaxFailures: 3,
+		ReplicaEjectTime:   xtime.Duration("30s"),
+		logger:             xlog.DefaultLogger,
 	}
 }
 
@@ -65,12 +103,57 @@ func (config *Config) WithLogger(log *xlog.Logger) *Config {
 
 // Build ...
 func (config *Config) Build() *Connection {
-	conn, err := Open(config.Drive, config.DSN, JupiterReceiver)
+	conn, err := Open(config.Drive, config.DSN, config.receiver())
 	if err != nil {
 		config.logger.Panic(fmt.Sprint("open ",config.Drive), xlog.FieldMod("dbr"), xlog.FieldErr(err), xlog.FieldValueAny(config))
 	}
-	conn.SetMaxIdleConns(config.MaxIdleConns)
-	conn.SetMaxOpenConns(config.MaxOpenConns)
-	conn.SetConnMaxLifetime(config.ConnMaxLifetime * time.Second)
+	if config.ConnMaxLifetime > 0 && config.ConnMaxLifetime < time.Second {
+		// 旧版本按秒解析整数, 如 300 表示300s, 现在表示300ns
+		legacy := config.ConnMaxLifetime * time.Second
+		config.logger.Warn("connMaxLifetime below 1s is read as seconds, write it as a duration such as \""+legacy.String()+"\"",
+			xlog.FieldMod("dbr"), xlog.FieldValueAny(config.ConnMaxLifetime))
+		config.ConnMaxLifetime = legacy
+	}
+	config.setPool(conn.DB)
+	if len(config.Replicas) > 0 {
+		pool := NewReplicaPool(config.ReplicaPolicy)
+		pool.MaxFailures = config.ReplicaMaxFailures
+		pool.EjectTime = config.ReplicaEjectTime
+		pool.ReadYourWrites = config.ReadYourWrites
+		for _, replica := range config.Replicas {
+			db, err := sql.Open(config.Drive, replica.DSN)
+			if err != nil {
+				config.logger.Panic("open replica", xlog.FieldMod("dbr"), xlog.FieldErr(err), xlog.FieldValueAny(config))
+			}
+			config.setPool(db)
+			pool.Add(db, replica.Weight)
+		}
+		conn.WithReplicas(pool)
+	}
+	if config.Prepare {
+		if config.Drive == "clickhouse" {
+			config.logger.Panic("prepared statements not supported", xlog.FieldMod("dbr"), xlog.FieldValueAny(config))
+		}
+		conn.WithStmtCache(config.StmtCacheSize)
+	}
 	return conn
 }
+
+// setPool 设置主库和副本的连接池
+func (config *Config) setPool(db *sql.DB) {
+	db.SetMaxIdleConns(config.MaxIdleConns)
+	db.SetMaxOpenConns(config.MaxOpenConns)
+	db.SetConnMaxLifetime(config.ConnMaxLifetime)
+}
+
+// receiver 配置的日志以及未关闭的指标和链路追踪
+func (config *Config) receiver() EventReceiver {
+	receivers := MultiEventReceiver{newLogReceiver(config)}
+	if !config.DisableMetric {
+		receivers = append(receivers, MetricReceiver)
+	}
+	if !config.DisableTrace {
+		receivers = append(receivers, TraceReceiver)
+	}
+	return receivers
+}
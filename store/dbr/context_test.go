@@ -0,0 +1,75 @@
+package dbr_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/system18188/jupiter-plugin/store/dbr"
+	"github.com/system18188/jupiter-plugin/store/dbr/dbrtest"
+)
+
+type ctxKey struct{}
+
+type tracer struct {
+	dbr.NullEventReceiver
+	spans  []string
+	errs   int
+	finish int
+}
+
+func (r *tracer) SpanStart(ctx context.Context, eventName, query string) context.Context {
+	v, _ := ctx.Value(ctxKey{}).(string)
+	r.spans = append(r.spans, v+":"+eventName)
+	return ctx
+}
+
+func (r *tracer) SpanError(ctx context.Context, err error) {
+	r.errs++
+}
+
+func (r *tracer) SpanFinish(ctx context.Context) {
+	r.finish++
+}
+
+func TestContext(t *testing.T) {
+	r := &tracer{}
+	sess := dbrtest.Open(t, "CREATE TABLE t (id INTEGER PRIMARY KEY)").NewSession(r)
+	ctx := context.WithValue(context.Background(), ctxKey{}, "req")
+
+	if _, err := sess.InsertInto("t").Pair("id", 1).ExecContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	id, err := sess.Select("id").From("t").ReturnInt64Context(ctx)
+	if err != nil || id != 1 {
+		t.Fatalf("id %d %v", id, err)
+	}
+	tx, err := sess.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.DeleteFrom("t").ExecContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	tx.Commit()
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	var ids []int64
+	if _, err := sess.Select("id").From("t").LoadContext(canceled, &ids); !errors.Is(err, context.Canceled) {
+		t.Fatalf("canceled query: %v", err)
+	}
+	if err := sess.Select("id").From("t").LoadOne(&id); err != dbr.ErrNotFound {
+		t.Fatalf("LoadOne: %v", err)
+	}
+
+	want := []string{"req:dbr.exec", "req:dbr.select", "req:dbr.exec", "req:dbr.select", ":dbr.select"}
+	if len(r.spans) != len(want) || r.errs != 1 || r.finish != len(want) {
+		t.Fatalf("spans %v errs %d finish %d", r.spans, r.errs, r.finish)
+	}
+	for i := range want {
+		if r.spans[i] != want[i] {
+			t.Fatalf("spans %v, want %v", r.spans, want)
+		}
+	}
+}
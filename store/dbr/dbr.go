@@ -45,13 +45,23 @@ type Connection struct {
 	*sql.DB
 	Dialect Dialect
 	EventReceiver
+	// 为nil时参数插值到SQL中, 见 WithStmtCache
+	stmts *StmtCache
+	// 为nil时不做读写分离, 见 WithReplicas
+	replicas *ReplicaPool
 }
 
 // Session represents a business unit of execution for some connection
 type Session struct {
+	// 最后一次写入的时间, unix纳秒, 放在首位保证64位对齐
+	lastWrite int64
 	*Connection
 	EventReceiver
 	ctx context.Context
+	// 忽略连接的预处理语句, 见 Interpolated
+	interpolate bool
+	// 查询也使用主库, 见 UsePrimary
+	primary bool
 }
 
 // NewSession instantiates a Session for the Connection
@@ -72,7 +82,11 @@ func (sess *Session) NewSession(log EventReceiver) *Session {
 	if log == nil {
 		log = sess.EventReceiver
 	}
-	return &Session{Connection: sess.Connection, EventReceiver: log, ctx: sess.ctx}
+	return &Session{Connection: sess.Connection, EventReceiver: log, ctx: sess.ctx, interpolate: sess.interpolate, primary: sess.primary}
+}
+
+func (sess *Session) context() context.Context {
+	return sess.ctx
 }
 
 // beginTx starts a transaction with context.
@@ -96,28 +110,50 @@ type SessionRunner interface {
 }
 
 type runner interface {
-	Exec(query string, args ...interface{}) (sql.Result, error)
-	Query(query string, args ...interface{}) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	// context returns the context of the session, used by the calls without a context
+	context() context.Context
 }
 
 // Executer can execute requests to database
 type Executer interface {
 	Exec() (sql.Result, error)
+	ExecContext(ctx context.Context) (sql.Result, error)
 }
 
 type loader interface {
 	Load(value interface{}) (int, error)
+	LoadOne(value interface{}) error
 	LoadStruct(value interface{}) error
 	LoadStructs(value interface{}) (int, error)
 	LoadValue(value interface{}) error
 	LoadValues(value interface{}) (int, error)
+
+	LoadContext(ctx context.Context, value interface{}) (int, error)
+	LoadOneContext(ctx context.Context, value interface{}) error
+	LoadStructContext(ctx context.Context, value interface{}) error
+	LoadStructsContext(ctx context.Context, value interface{}) (int, error)
+	LoadValueContext(ctx context.Context, value interface{}) error
+	LoadValuesContext(ctx context.Context, value interface{}) (int, error)
+}
+
+// outcome is the outcome kv of the timing events
+func outcome(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
 }
 
-func exec(runner runner, log EventReceiver, builder Builder, d Dialect) (sql.Result, error) {
+func exec(ctx context.Context, runner runner, log EventReceiver, builder Builder, d Dialect) (sql.Result, error) {
+	p, bind := runner.(preparer)
+	bind = bind && p.prepared()
 	i := interpolator{
 		Buffer:       NewBuffer(),
 		Dialect:      d,
 		IgnoreBinary: true,
+		Bind:         bind,
 	}
 	err := i.interpolate(placeholder, []interface{}{builder})
 	query, value := i.String(), i.Value()
@@ -128,27 +164,59 @@ func exec(runner runner, log EventReceiver, builder Builder, d Dialect) (sql.Res
 		})
 	}
 
+	detail := detailOf(log, builder, d, query, bind)
+
+	if tr, ok := log.(TracingEventReceiver); ok {
+		ctx = tr.SpanStart(ctx, "dbr.exec", placeholderSQL(builder, d, query, bind))
+		defer tr.SpanFinish(ctx)
+		defer func() {
+			if err != nil {
+				tr.SpanError(ctx, err)
+			}
+		}()
+	}
+
 	startTime := time.Now()
 	defer func() {
 		log.TimingKv("dbr.exec", time.Since(startTime).Nanoseconds(), kvs{
-			"sql": query,
-		})
+			"sql":     query,
+			"outcome": outcome(err),
+		}.withDetail(detail))
 	}()
 
-	result, err := runner.Exec(query, value...)
+	var result sql.Result
+	if bind {
+		var stmt *sql.Stmt
+		var release func()
+		if stmt, release, err = p.prepare(ctx, query); err != nil {
+			return nil, log.EventErrKv("dbr.exec.prepare", err, kvs{
+				"sql": query,
+			}.withDetail(detail))
+		}
+		defer release()
+		result, err = stmt.ExecContext(ctx, value...)
+	} else {
+		result, err = runner.ExecContext(ctx, query, value...)
+	}
 	if err != nil {
 		return result, log.EventErrKv("dbr.exec.exec", err, kvs{
 			"sql": query,
-		})
+		}.withDetail(detail))
+	}
+	if w, ok := runner.(writeMarker); ok {
+		w.markWrite()
 	}
 	return result, nil
 }
 
-func query(runner runner, log EventReceiver, builder Builder, d Dialect, dest interface{}) (int, error) {
+func query(ctx context.Context, runner runner, log EventReceiver, builder Builder, d Dialect, dest interface{}) (int, error) {
+	p, bind := runner.(preparer)
+	bind = bind && p.prepared()
 	i := interpolator{
 		Buffer:       NewBuffer(),
 		Dialect:      d,
 		IgnoreBinary: true,
+		Bind:         bind,
 	}
 	err := i.interpolate(placeholder, []interface{}{builder})
 	query, value := i.String(), i.Value()
@@ -159,24 +227,50 @@ func query(runner runner, log EventReceiver, builder Builder, d Dialect, dest in
 		})
 	}
 
+	detail := detailOf(log, builder, d, query, bind)
+
+	if tr, ok := log.(TracingEventReceiver); ok {
+		ctx = tr.SpanStart(ctx, "dbr.select", placeholderSQL(builder, d, query, bind))
+		defer tr.SpanFinish(ctx)
+		defer func() {
+			if err != nil {
+				tr.SpanError(ctx, err)
+			}
+		}()
+	}
+
 	startTime := time.Now()
 	defer func() {
 		log.TimingKv("dbr.select", time.Since(startTime).Nanoseconds(), kvs{
-			"sql": query,
-		})
+			"sql":     query,
+			"outcome": outcome(err),
+		}.withDetail(detail))
 	}()
 
-	rows, err := runner.Query(query, value...)
+	var rows *sql.Rows
+	if bind {
+		var stmt *sql.Stmt
+		var release func()
+		if stmt, release, err = p.prepare(ctx, query); err != nil {
+			return 0, log.EventErrKv("dbr.select.prepare", err, kvs{
+				"sql": query,
+			}.withDetail(detail))
+		}
+		defer release()
+		rows, err = stmt.QueryContext(ctx, value...)
+	} else {
+		rows, err = runner.QueryContext(ctx, query, value...)
+	}
 	if err != nil {
 		return 0, log.EventErrKv("dbr.select.load.query", err, kvs{
 			"sql": query,
-		})
+		}.withDetail(detail))
 	}
 	count, err := Load(rows, dest)
 	if err != nil {
 		return 0, log.EventErrKv("dbr.select.load.scan", err, kvs{
 			"sql": query,
-		})
+		}.withDetail(detail))
 	}
 	return count, nil
 }
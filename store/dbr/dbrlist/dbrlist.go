@@ -0,0 +1,347 @@
+// Package dbrlist implements the list endpoint conventions: page, per_page and
+// sort query parameters, filters on allowlisted columns, a COUNT of the matching
+// rows and a page envelope with Link headers.
+//
+//	var userList = dbrlist.NewSpec().
+//		Sort("id", "created_at").
+//		Filter("status", dbrlist.OpEq, dbrlist.OpIn).
+//		Filter("created_at", dbrlist.OpGte, dbrlist.OpLt).
+//		WithDefaultSort("-id")
+//
+//	func listUsers(req *restful.Request, resp *restful.Response) {
+//		q, err := userList.Parse(req.Request.URL.Query())
+//		if err != nil {
+//			resp.WriteErrorString(http.StatusBadRequest, err.Error())
+//			return
+//		}
+//		stmt := sess.Select("*").From("users")
+//		total, err := q.ApplyContext(req.Request.Context(), stmt)
+//		...
+//		var users []User
+//		_, err = stmt.LoadStructs(&users)
+//		...
+//		q.Render(req, resp, total, users)
+//	}
+//
+// Query syntax: ?page=2&per_page=20&sort=-created_at,id&status=active&status[in]=a,b&created_at[gte]=2020-01-01
+package dbrlist
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	restful "github.com/emicklei/go-restful/v3"
+	"github.com/system18188/jupiter-plugin/server/xrestful"
+	"github.com/system18188/jupiter-plugin/store/dbr"
+	"github.com/system18188/jupiter-plugin/store/dbr/condition"
+)
+
+// Query parameter names
+const (
+	ParamPage    = "page"
+	ParamPerPage = "per_page"
+	ParamSort    = "sort"
+
+	// HeaderTotalCount ...
+	HeaderTotalCount = "X-Total-Count"
+)
+
+// Op is a filter operator, written as field[op]=value, eq is also written as field=value
+type Op string
+
+// Filter operators
+const (
+	OpEq  Op = "eq"
+	OpNe  Op = "ne"
+	OpGt  Op = "gt"
+	OpGte Op = "gte"
+	OpLt  Op = "lt"
+	OpLte Op = "lte"
+	// 逗号分隔的多个值
+	OpIn Op = "in"
+)
+
+type field struct {
+	column string
+	ops    []Op
+}
+
+// Spec is the allowlist of one list endpoint
+type Spec struct {
+	sortable   map[string]string
+	filterable map[string]*field
+	// 默认排序, 如 -id
+	DefaultSort string
+	// 默认每页条数, 默认20
+	DefaultPerPage uint64
+	// 每页条数上限, 默认100
+	MaxPerPage uint64
+}
+
+// NewSpec ...
+func NewSpec() *Spec {
+	return &Spec{
+		sortable:       make(map[string]string),
+		filterable:     make(map[string]*field),
+		DefaultPerPage: 20,
+		MaxPerPage:     100,
+	}
+}
+
+// Sort allows sorting by fields, each field is also the column name
+func (spec *Spec) Sort(fields ...string) *Spec {
+	for _, f := range fields {
+		spec.sortable[f] = f
+	}
+	return spec
+}
+
+// SortColumn allows sorting by name, mapped to column such as users.created_at
+func (spec *Spec) SortColumn(name, column string) *Spec {
+	spec.sortable[name] = column
+	return spec
+}
+
+// Filter allows filtering name with ops, name is also the column name
+func (spec *Spec) Filter(name string, ops ...Op) *Spec {
+	return spec.FilterColumn(name, name, ops...)
+}
+
+// FilterColumn allows filtering name with ops, mapped to column
+func (spec *Spec) FilterColumn(name, column string, ops ...Op) *Spec {
+	if len(ops) == 0 {
+		ops = []Op{OpEq}
+	}
+	spec.filterable[name] = &field{column: column, ops: ops}
+	return spec
+}
+
+// WithDefaultSort ...
+func (spec *Spec) WithDefaultSort(sort string) *Spec {
+	spec.DefaultSort = sort
+	return spec
+}
+
+// WithPerPage ...
+func (spec *Spec) WithPerPage(defaultPerPage, maxPerPage uint64) *Spec {
+	spec.DefaultPerPage, spec.MaxPerPage = defaultPerPage, maxPerPage
+	return spec
+}
+
+// Order is one sort column
+type Order struct {
+	Column string
+	Asc    bool
+}
+
+// Filter is one parsed filter
+type Filter struct {
+	Column string
+	Op     Op
+	Value  interface{}
+}
+
+// Query is a validated list query
+type Query struct {
+	Page    uint64
+	PerPage uint64
+	Orders  []Order
+	Filters []Filter
+	params  url.Values
+}
+
+// Parse validates params against the spec. Unknown sort fields, filters or
+// operators are errors, parameters other than page, per_page and sort that
+// are not filterable are ignored.
+func (spec *Spec) Parse(params url.Values) (*Query, error) {
+	q := &Query{Page: 1, PerPage: spec.DefaultPerPage, params: params}
+	if v := params.Get(ParamPage); v != "" {
+		page, err := strconv.ParseUint(v, 10, 64)
+		if err != nil || page == 0 {
+			return nil, fmt.Errorf("invalid %s %q", ParamPage, v)
+		}
+		q.Page = page
+	}
+	if v := params.Get(ParamPerPage); v != "" {
+		perPage, err := strconv.ParseUint(v, 10, 64)
+		if err != nil || perPage == 0 {
+			return nil, fmt.Errorf("invalid %s %q", ParamPerPage, v)
+		}
+		q.PerPage = perPage
+	}
+	if spec.MaxPerPage > 0 && q.PerPage > spec.MaxPerPage {
+		q.PerPage = spec.MaxPerPage
+	}
+
+	sorts := params.Get(ParamSort)
+	if sorts == "" {
+		sorts = spec.DefaultSort
+	}
+	for _, name := range strings.Split(sorts, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		asc := true
+		if strings.HasPrefix(name, "-") {
+			name, asc = name[1:], false
+		} else {
+			name = strings.TrimPrefix(name, "+")
+		}
+		column, ok := spec.sortable[name]
+		if !ok {
+			return nil, fmt.Errorf("cannot sort by %q", name)
+		}
+		q.Orders = append(q.Orders, Order{Column: column, Asc: asc})
+	}
+
+	// 按参数名排序, 相同的查询生成相同的SQL
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		values := params[key]
+		name, op, bracket := key, OpEq, false
+		if i := strings.Index(key, "["); i > 0 && strings.HasSuffix(key, "]") {
+			name, op, bracket = key[:i], Op(key[i+1:len(key)-1]), true
+		}
+		f, ok := spec.filterable[name]
+		if !ok {
+			if bracket {
+				return nil, fmt.Errorf("cannot filter by %q", name)
+			}
+			continue
+		}
+		if !f.allows(op) {
+			return nil, fmt.Errorf("cannot filter %q with %s", name, op)
+		}
+		for _, value := range values {
+			var v interface{} = value
+			if op == OpIn {
+				v = strings.Split(value, ",")
+			}
+			q.Filters = append(q.Filters, Filter{Column: f.column, Op: op, Value: v})
+		}
+	}
+	return q, nil
+}
+
+func (f *field) allows(op Op) bool {
+	for _, o := range f.ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply adds the filters to stmt, counts the matching rows, then adds the
+// order and the page. It returns the total number of rows, stmt must implement
+// dbr.Counter or dbr.ErrNotSupported is returned.
+func (q *Query) Apply(stmt dbr.SelectBuilder) (uint64, error) {
+	return q.apply(stmt, dbr.Counter.Count)
+}
+
+// ApplyContext is Apply with the count run with ctx
+func (q *Query) ApplyContext(ctx context.Context, stmt dbr.SelectBuilder) (uint64, error) {
+	return q.apply(stmt, func(c dbr.Counter) (uint64, error) {
+		return c.CountContext(ctx)
+	})
+}
+
+func (q *Query) apply(stmt dbr.SelectBuilder, count func(dbr.Counter) (uint64, error)) (uint64, error) {
+	counter, ok := stmt.(dbr.Counter)
+	if !ok {
+		return 0, dbr.ErrNotSupported
+	}
+	builders := condition.NewBuilders()
+	for _, f := range q.Filters {
+		builders.Append(f.condition())
+	}
+	builders.ScanSelectBuilder(stmt)
+	total, err := count(counter)
+	if err != nil {
+		return 0, err
+	}
+	for _, o := range q.Orders {
+		stmt.OrderDir(o.Column, o.Asc)
+	}
+	stmt.Paginate(q.Page, q.PerPage)
+	return total, nil
+}
+
+func (f Filter) condition() condition.Builder {
+	switch f.Op {
+	case OpNe:
+		return condition.Neq(f.Column, f.Value, true)
+	case OpGt:
+		return condition.Gt(f.Column, f.Value, true)
+	case OpGte:
+		return condition.Gte(f.Column, f.Value, true)
+	case OpLt:
+		return condition.Lt(f.Column, f.Value, true)
+	case OpLte:
+		return condition.Lte(f.Column, f.Value, true)
+	default:
+		// eq, 以及值为切片的 in
+		return condition.Eq(f.Column, f.Value, true)
+	}
+}
+
+// Page is the envelope of a list response
+type Page struct {
+	Items   interface{} `json:"items" xml:"items" yaml:"items"`
+	Total   uint64      `json:"total" xml:"total" yaml:"total"`
+	Page    uint64      `json:"page" xml:"page" yaml:"page"`
+	PerPage uint64      `json:"per_page" xml:"per_page" yaml:"per_page"`
+}
+
+// LastPage returns the number of the last page, 1 when there is no row
+func (q *Query) LastPage(total uint64) uint64 {
+	if total == 0 {
+		return 1
+	}
+	return (total + q.PerPage - 1) / q.PerPage
+}
+
+// Links returns the RFC 5988 Link header of the first, prev, next and last pages of u
+func (q *Query) Links(u *url.URL, total uint64) string {
+	last := q.LastPage(total)
+	link := func(page uint64, rel string) string {
+		params := url.Values{}
+		for k, v := range q.params {
+			params[k] = v
+		}
+		params.Set(ParamPage, strconv.FormatUint(page, 10))
+		params.Set(ParamPerPage, strconv.FormatUint(q.PerPage, 10))
+		target := url.URL{Path: u.Path, RawQuery: params.Encode()}
+		return fmt.Sprintf(`<%s>; rel="%s"`, target.String(), rel)
+	}
+	links := []string{link(1, "first")}
+	if q.Page > 1 {
+		links = append(links, link(q.Page-1, "prev"))
+	}
+	if q.Page < last {
+		links = append(links, link(q.Page+1, "next"))
+	}
+	links = append(links, link(last, "last"))
+	return strings.Join(links, ", ")
+}
+
+// Render writes the page envelope of items with the Link and X-Total-Count headers
+func (q *Query) Render(req *restful.Request, resp *restful.Response, total uint64, items interface{}) error {
+	resp.AddHeader("Link", q.Links(req.Request.URL, total))
+	resp.AddHeader(HeaderTotalCount, strconv.FormatUint(total, 10))
+	return xrestful.Render(resp, http.StatusOK, &Page{
+		Items:   items,
+		Total:   total,
+		Page:    q.Page,
+		PerPage: q.PerPage,
+	})
+}
@@ -0,0 +1,196 @@
+package dbrlist
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	restful "github.com/emicklei/go-restful/v3"
+	"github.com/system18188/jupiter-plugin/store/dbr"
+	"github.com/system18188/jupiter-plugin/store/dbr/dbrtest"
+)
+
+type item struct {
+	ID     int64  `db:"id" json:"id"`
+	Status string `db:"status" json:"status"`
+	Score  int64  `db:"score" json:"score"`
+}
+
+func newSession(t *testing.T) *dbr.Session {
+	sess := dbrtest.Session(t, "CREATE TABLE items (id INTEGER PRIMARY KEY, status TEXT, score INTEGER)")
+	for i := 1; i <= 25; i++ {
+		status := "active"
+		if i%5 == 0 {
+			status = "closed"
+		}
+		if _, err := sess.InsertInto("items").Pair("id", i).Pair("status", status).Pair("score", i%7).Exec(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return sess
+}
+
+var spec = NewSpec().
+	Sort("id", "score").
+	Filter("status", OpEq, OpIn).
+	Filter("score", OpGte, OpLt).
+	WithDefaultSort("-id").
+	WithPerPage(10, 15)
+
+func TestParseErrors(t *testing.T) {
+	for _, raw := range []string{
+		"page=0",
+		"page=x",
+		"per_page=-1",
+		"sort=password",
+		"status[gt]=a",
+		"secret[eq]=1",
+	} {
+		params, _ := url.ParseQuery(raw)
+		if _, err := spec.Parse(params); err == nil {
+			t.Errorf("%s: want error", raw)
+		}
+	}
+	q, err := spec.Parse(url.Values{"per_page": {"50"}, "other": {"1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.PerPage != 15 || len(q.Filters) != 0 || len(q.Orders) != 1 || q.Orders[0].Asc {
+		t.Fatalf("query %+v", q)
+	}
+}
+
+func TestApply(t *testing.T) {
+	sess := newSession(t)
+	cases := []struct {
+		query string
+		total uint64
+		ids   string
+	}{
+		{"", 25, "[25 24 23 22 21 20 19 18 17 16]"},
+		{"page=3", 25, "[5 4 3 2 1]"},
+		{"status=closed&sort=id", 5, "[5 10 15 20 25]"},
+		{"status[in]=closed,none&per_page=2&page=2", 5, "[15 10]"},
+		{"score[gte]=5&score[lt]=7&sort=-score,id", 6, "[6 13 20 5 12 19]"},
+	}
+	for _, c := range cases {
+		params, _ := url.ParseQuery(c.query)
+		q, err := spec.Parse(params)
+		if err != nil {
+			t.Fatalf("%s: %v", c.query, err)
+		}
+		stmt := sess.Select("*").From("items")
+		total, err := q.Apply(stmt)
+		if err != nil {
+			t.Fatalf("%s: %v", c.query, err)
+		}
+		var items []item
+		if _, err := stmt.LoadStructs(&items); err != nil {
+			t.Fatalf("%s: %v", c.query, err)
+		}
+		ids := make([]int64, 0, len(items))
+		for _, it := range items {
+			ids = append(ids, it.ID)
+		}
+		if total != c.total || fmt.Sprint(ids) != c.ids {
+			t.Errorf("%s: total %d ids %v, want %d %s", c.query, total, ids, c.total, c.ids)
+		}
+	}
+}
+
+// selectOnly hides the Counter of the wrapped builder
+type selectOnly struct {
+	dbr.SelectBuilder
+}
+
+func TestApplyContext(t *testing.T) {
+	sess := newSession(t)
+	q, _ := spec.Parse(url.Values{"status": {"closed"}})
+	ctx, cancel := context.WithCancel(context.Background())
+	if total, err := q.ApplyContext(ctx, sess.Select("*").From("items")); err != nil || total != 5 {
+		t.Fatalf("total %d %v", total, err)
+	}
+	cancel()
+	if _, err := q.ApplyContext(ctx, sess.Select("*").From("items")); err == nil {
+		t.Fatal("want canceled")
+	}
+	if _, err := q.Apply(selectOnly{sess.Select("*").From("items")}); err != dbr.ErrNotSupported {
+		t.Fatalf("err %v", err)
+	}
+}
+
+func TestCountGroupBy(t *testing.T) {
+	sess := newSession(t)
+	n, err := sess.Select("status").From("items").GroupBy("status").(dbr.Counter).Count()
+	if err != nil || n != 2 {
+		t.Fatalf("count %d %v", n, err)
+	}
+	n, err = sess.Select("id").From("items").Where("score > ?", 4).OrderBy("id").Limit(1).(dbr.Counter).Count()
+	if err != nil || n != 6 {
+		t.Fatalf("count %d %v", n, err)
+	}
+}
+
+func TestRender(t *testing.T) {
+	sess := newSession(t)
+	ws := new(restful.WebService)
+	ws.Route(ws.GET("/items").Produces(restful.MIME_JSON).To(func(req *restful.Request, resp *restful.Response) {
+		q, err := spec.Parse(req.Request.URL.Query())
+		if err != nil {
+			resp.WriteErrorString(http.StatusBadRequest, err.Error())
+			return
+		}
+		stmt := sess.Select("*").From("items")
+		total, err := q.Apply(stmt)
+		if err != nil {
+			resp.WriteError(http.StatusInternalServerError, err)
+			return
+		}
+		var items []item
+		stmt.LoadStructs(&items)
+		q.Render(req, resp, total, items)
+	}))
+	container := restful.NewContainer()
+	container.Add(ws)
+
+	rec := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/items?page=2&status=active", nil)
+	r.Header.Set("Accept", restful.MIME_JSON)
+	container.ServeHTTP(rec, r)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d %s", rec.Code, rec.Body.String())
+	}
+	var page struct {
+		Items   []item `json:"items"`
+		Total   uint64 `json:"total"`
+		Page    uint64 `json:"page"`
+		PerPage uint64 `json:"per_page"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 20 || page.Page != 2 || page.PerPage != 10 || len(page.Items) != 10 {
+		t.Fatalf("page %+v", page)
+	}
+	if rec.Header().Get(HeaderTotalCount) != "20" {
+		t.Errorf("total header %q", rec.Header().Get(HeaderTotalCount))
+	}
+	link := rec.Header().Get("Link")
+	for _, want := range []string{
+		`</items?page=1&per_page=10&status=active>; rel="first"`,
+		`</items?page=1&per_page=10&status=active>; rel="prev"`,
+		`</items?page=2&per_page=10&status=active>; rel="last"`,
+	} {
+		if !strings.Contains(link, want) {
+			t.Errorf("link %q missing %q", link, want)
+		}
+	}
+	if strings.Contains(link, `rel="next"`) {
+		t.Errorf("link %q has next on the last page", link)
+	}
+}
@@ -0,0 +1,151 @@
+package dbrstore
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/system18188/jupiter-plugin/server/xrestful"
+	"github.com/system18188/jupiter-plugin/store/dbr"
+)
+
+// AuditSink is a xrestful.AuditSink writing to a table, e.g. for mysql:
+//
+//	CREATE TABLE audit_logs (
+//		seq        BIGINT UNSIGNED NOT NULL PRIMARY KEY,
+//		time       DATETIME(6)     NOT NULL,
+//		principal  VARCHAR(255)    NOT NULL,
+//		action     VARCHAR(16)     NOT NULL,
+//		resource   VARCHAR(1024)   NOT NULL,
+//		route      VARCHAR(1024)   NOT NULL,
+//		request_id VARCHAR(64)     NOT NULL,
+//		client_ip  VARCHAR(64)     NOT NULL,
+//		status     INT             NOT NULL,
+//		body       TEXT,
+//		diff       TEXT,
+//		prev_hash  CHAR(64)        NOT NULL,
+//		hash       CHAR(64)        NOT NULL
+//	);
+//
+// Instances of a service may share the table: seq is the primary key, so the
+// write of a writer behind the head fails and is chained again after LastAudit.
+type AuditSink struct {
+	sess  *dbr.Session
+	table string
+}
+
+type auditRow struct {
+	Seq       uint64    `db:"seq"`
+	Time      time.Time `db:"time"`
+	Principal string    `db:"principal"`
+	Action    string    `db:"action"`
+	Resource  string    `db:"resource"`
+	Route     string    `db:"route"`
+	RequestID string    `db:"request_id"`
+	ClientIP  string    `db:"client_ip"`
+	Status    int       `db:"status"`
+	Body      string    `db:"body"`
+	Diff      string    `db:"diff"`
+	PrevHash  string    `db:"prev_hash"`
+	Hash      string    `db:"hash"`
+}
+
+var auditColumns = []string{"seq", "time", "principal", "action", "resource", "route", "request_id", "client_ip", "status", "body", "diff", "prev_hash", "hash"}
+
+// NewAuditSink returns a sink using the "audit_logs" table
+func NewAuditSink(sess *dbr.Session) *AuditSink {
+	return NewAuditSinkWithTable(sess, "audit_logs")
+}
+
+// NewAuditSinkWithTable returns a sink using the given table, the queries go to
+// the primary so LastAudit returns the current head
+func NewAuditSinkWithTable(sess *dbr.Session, table string) *AuditSink {
+	return &AuditSink{
+		sess:  sess.UsePrimary(),
+		table: table,
+	}
+}
+
+// Write implements xrestful.AuditSink
+func (s *AuditSink) Write(record *xrestful.AuditRecord) error {
+	row := &auditRow{
+		Seq:       record.Seq,
+		Time:      record.Time,
+		Principal: record.Principal,
+		Action:    record.Action,
+		Resource:  record.Resource,
+		Route:     record.Route,
+		RequestID: record.RequestID,
+		ClientIP:  record.ClientIP,
+		Status:    record.Status,
+		Body:      string(record.Body),
+		PrevHash:  record.PrevHash,
+		Hash:      record.Hash,
+	}
+	if len(record.Diff) > 0 {
+		diff, err := json.Marshal(record.Diff)
+		if err != nil {
+			return err
+		}
+		row.Diff = string(diff)
+	}
+	_, err := s.sess.InsertInto(s.table).Columns(auditColumns...).Record(row).Exec()
+	return err
+}
+
+// LastAudit implements xrestful.AuditChainHead
+func (s *AuditSink) LastAudit() (*xrestful.AuditRecord, error) {
+	var row auditRow
+	err := s.sess.Select(auditColumns...).From(s.table).OrderDesc("seq").Limit(1).LoadStruct(&row)
+	if err == dbr.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return row.record()
+}
+
+// Records returns the records with seq in [from, to] ordered by seq, for xrestful.VerifyAuditChain
+func (s *AuditSink) Records(from, to uint64) ([]*xrestful.AuditRecord, error) {
+	var rows []auditRow
+	_, err := s.sess.Select(auditColumns...).From(s.table).
+		Where(dbr.And(dbr.Gte("seq", from), dbr.Lte("seq", to))).
+		OrderAsc("seq").LoadStructs(&rows)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]*xrestful.AuditRecord, 0, len(rows))
+	for _, row := range rows {
+		record, err := row.record()
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (row *auditRow) record() (*xrestful.AuditRecord, error) {
+	record := &xrestful.AuditRecord{
+		Seq:       row.Seq,
+		Time:      row.Time.UTC(),
+		Principal: row.Principal,
+		Action:    row.Action,
+		Resource:  row.Resource,
+		Route:     row.Route,
+		RequestID: row.RequestID,
+		ClientIP:  row.ClientIP,
+		Status:    row.Status,
+		PrevHash:  row.PrevHash,
+		Hash:      row.Hash,
+	}
+	if row.Body != "" {
+		record.Body = json.RawMessage(row.Body)
+	}
+	if row.Diff != "" {
+		if err := json.Unmarshal([]byte(row.Diff), &record.Diff); err != nil {
+			return nil, err
+		}
+	}
+	return record, nil
+}
@@ -0,0 +1,80 @@
+package dbrstore_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/system18188/jupiter-plugin/server/xrestful"
+	"github.com/system18188/jupiter-plugin/store/dbr/dbrstore"
+	"github.com/system18188/jupiter-plugin/store/dbr/dbrtest"
+)
+
+const auditSchema = `CREATE TABLE audit_logs (
+	seq        INTEGER      NOT NULL PRIMARY KEY,
+	time       DATETIME     NOT NULL,
+	principal  VARCHAR(255) NOT NULL,
+	action     VARCHAR(16)  NOT NULL,
+	resource   VARCHAR(1024) NOT NULL,
+	route      VARCHAR(1024) NOT NULL,
+	request_id VARCHAR(64)  NOT NULL,
+	client_ip  VARCHAR(64)  NOT NULL,
+	status     INT          NOT NULL,
+	body       TEXT,
+	diff       TEXT,
+	prev_hash  CHAR(64)     NOT NULL,
+	hash       CHAR(64)     NOT NULL
+)`
+
+func TestAuditSink(t *testing.T) {
+	sink := dbrstore.NewAuditSink(dbrtest.Session(t, auditSchema))
+
+	last, err := sink.LastAudit()
+	if err != nil || last != nil {
+		t.Fatalf("empty table: %v %v", last, err)
+	}
+
+	// 与 AuditConfig 一样时间精确到微秒, Diff 的值来自解码的 JSON
+	var diff []xrestful.AuditChange
+	if err := json.Unmarshal([]byte(`[{"field":"age","old":1,"new":2.5},{"field":"tags","old":null,"new":["a",{"b":true}]}]`), &diff); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 10, 19, 8, 30, 0, 123456789, time.UTC).Truncate(time.Microsecond)
+	records := []*xrestful.AuditRecord{
+		{Time: now, Principal: "alice", Action: "PUT", Resource: "/users/1", Route: "/users/{id}", Status: 204,
+			Body: json.RawMessage(`{"name":"b","password":"***"}`), Diff: diff},
+		{Time: now.Add(time.Second), Principal: "bob", Action: "DELETE", Resource: "/users/1", Route: "/users/{id}", Status: 204},
+		{Time: now.Add(2 * time.Second), Action: "POST", Resource: "/users", Route: "/users", Status: 201, RequestID: "req-1", ClientIP: "10.0.0.1"},
+	}
+	prev := ""
+	for i, record := range records {
+		record.Seq = uint64(i + 1)
+		record.PrevHash = prev
+		record.Hash = xrestful.AuditHash(record)
+		prev = record.Hash
+		if err := sink.Write(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	last, err = sink.LastAudit()
+	if err != nil || last == nil || last.Seq != 3 || last.Hash != records[2].Hash {
+		t.Fatalf("last = %+v, %v", last, err)
+	}
+	read, err := sink.Records(1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read) != 3 {
+		t.Fatalf("%d records", len(read))
+	}
+	if err := xrestful.VerifyAuditChain(read); err != nil {
+		t.Fatal(err)
+	}
+	if !read[0].Time.Equal(now) {
+		t.Errorf("time = %v", read[0].Time)
+	}
+	if read, err = sink.Records(2, 3); err != nil || len(read) != 2 {
+		t.Fatalf("%d records, %v", len(read), err)
+	}
+}
@@ -0,0 +1,139 @@
+package dbrstore
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/system18188/jupiter-plugin/server/xrestful"
+	"github.com/system18188/jupiter-plugin/store/dbr"
+)
+
+// IdempotencyStore is a xrestful.IdempotencyStore backed by a table, e.g. for mysql:
+//
+//	CREATE TABLE idempotency_keys (
+//		idempotency_key VARCHAR(255) NOT NULL PRIMARY KEY,
+//		fingerprint     CHAR(64)     NOT NULL,
+//		done            TINYINT(1)   NOT NULL DEFAULT 0,
+//		status          INT          NOT NULL DEFAULT 0,
+//		header          TEXT,
+//		body            MEDIUMBLOB,
+//		created_at      DATETIME(6)  NOT NULL,
+//		expires_at      DATETIME(6)  NOT NULL
+//	);
+type IdempotencyStore struct {
+	sess  *dbr.Session
+	table string
+}
+
+type idempotencyRow struct {
+	IdempotencyKey string    `db:"idempotency_key"`
+	Fingerprint    string    `db:"fingerprint"`
+	Done           bool      `db:"done"`
+	Status         int       `db:"status"`
+	Header         string    `db:"header"`
+	Body           []byte    `db:"body"`
+	CreatedAt      time.Time `db:"created_at"`
+	ExpiresAt      time.Time `db:"expires_at"`
+}
+
+var idempotencyColumns = []string{"idempotency_key", "fingerprint", "done", "status", "header", "body", "created_at", "expires_at"}
+
+// NewIdempotencyStore returns a store using the "idempotency_keys" table
+func NewIdempotencyStore(sess *dbr.Session) *IdempotencyStore {
+	return NewIdempotencyStoreWithTable(sess, "idempotency_keys")
+}
+
+// NewIdempotencyStoreWithTable returns a store using the given table, the queries
+// go to the primary: a replica may not have the row of a concurrent request yet
+func NewIdempotencyStoreWithTable(sess *dbr.Session, table string) *IdempotencyStore {
+	return &IdempotencyStore{
+		sess:  sess.UsePrimary(),
+		table: table,
+	}
+}
+
+// Acquire implements xrestful.IdempotencyStore, the primary key makes the insert atomic
+func (s *IdempotencyStore) Acquire(key string, record *xrestful.IdempotencyRecord, ttl time.Duration) (*xrestful.IdempotencyRecord, bool, error) {
+	now := time.Now()
+	// 先清理该key已过期的记录
+	if _, err := s.sess.DeleteFrom(s.table).Where(dbr.And(dbr.Eq("idempotency_key", key), dbr.Lt("expires_at", now))).Exec(); err != nil {
+		return nil, false, err
+	}
+	row, err := newIdempotencyRow(key, record, now.Add(ttl))
+	if err != nil {
+		return nil, false, err
+	}
+	_, insertErr := s.sess.InsertInto(s.table).Columns(idempotencyColumns...).Record(row).Exec()
+	if insertErr == nil {
+		return nil, true, nil
+	}
+	var existing idempotencyRow
+	err = s.sess.Select(idempotencyColumns...).From(s.table).Where(dbr.Eq("idempotency_key", key)).LoadStruct(&existing)
+	if err == dbr.ErrNotFound {
+		// 插入失败不是因为key冲突
+		return nil, false, insertErr
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return existing.record()
+}
+
+// Complete implements xrestful.IdempotencyStore
+func (s *IdempotencyStore) Complete(key string, record *xrestful.IdempotencyRecord, ttl time.Duration) error {
+	header, err := json.Marshal(record.Header)
+	if err != nil {
+		return err
+	}
+	_, err = s.sess.Update(s.table).
+		Set("done", record.Done).
+		Set("status", record.Status).
+		Set("header", string(header)).
+		Set("body", record.Body).
+		Set("expires_at", time.Now().Add(ttl)).
+		Where(dbr.Eq("idempotency_key", key)).
+		Exec()
+	return err
+}
+
+// Release implements xrestful.IdempotencyStore
+func (s *IdempotencyStore) Release(key string) error {
+	_, err := s.sess.DeleteFrom(s.table).Where(dbr.Eq("idempotency_key", key)).Exec()
+	return err
+}
+
+func newIdempotencyRow(key string, record *xrestful.IdempotencyRecord, expiresAt time.Time) (*idempotencyRow, error) {
+	header, err := json.Marshal(record.Header)
+	if err != nil {
+		return nil, err
+	}
+	return &idempotencyRow{
+		IdempotencyKey: key,
+		Fingerprint:    record.Fingerprint,
+		Done:           record.Done,
+		Status:         record.Status,
+		Header:         string(header),
+		Body:           record.Body,
+		CreatedAt:      record.CreatedAt,
+		ExpiresAt:      expiresAt,
+	}, nil
+}
+
+func (row *idempotencyRow) record() (*xrestful.IdempotencyRecord, bool, error) {
+	var header http.Header
+	if row.Header != "" {
+		if err := json.Unmarshal([]byte(row.Header), &header); err != nil {
+			return nil, false, err
+		}
+	}
+	return &xrestful.IdempotencyRecord{
+		Key:         row.IdempotencyKey,
+		Fingerprint: row.Fingerprint,
+		Done:        row.Done,
+		Status:      row.Status,
+		Header:      header,
+		Body:        row.Body,
+		CreatedAt:   row.CreatedAt,
+	}, false, nil
+}
@@ -0,0 +1,76 @@
+package dbrstore_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/system18188/jupiter-plugin/server/xrestful"
+	"github.com/system18188/jupiter-plugin/store/dbr"
+	"github.com/system18188/jupiter-plugin/store/dbr/dbrstore"
+	"github.com/system18188/jupiter-plugin/store/dbr/dbrtest"
+)
+
+const idempotencySchema = `CREATE TABLE idempotency_keys (
+	idempotency_key VARCHAR(255) NOT NULL PRIMARY KEY,
+	fingerprint     CHAR(64)     NOT NULL,
+	done            TINYINT(1)   NOT NULL DEFAULT 0,
+	status          INT          NOT NULL DEFAULT 0,
+	header          TEXT,
+	body            BLOB,
+	created_at      DATETIME     NOT NULL,
+	expires_at      DATETIME     NOT NULL
+)`
+
+func TestIdempotencyStore(t *testing.T) {
+	store := dbrstore.NewIdempotencyStore(dbrtest.Session(t, idempotencySchema))
+	record := &xrestful.IdempotencyRecord{Key: "k1", Fingerprint: "f1", CreatedAt: time.Now()}
+
+	if existing, acquired, err := store.Acquire("k1", record, time.Minute); err != nil || !acquired || existing != nil {
+		t.Fatalf("first acquire: %v %v %v", existing, acquired, err)
+	}
+	existing, acquired, err := store.Acquire("k1", record, time.Minute)
+	if err != nil || acquired || existing == nil || existing.Done || existing.Fingerprint != "f1" {
+		t.Fatalf("in progress: %+v %v %v", existing, acquired, err)
+	}
+
+	done := *record
+	done.Done = true
+	done.Status = http.StatusCreated
+	done.Header = http.Header{"Content-Type": {"application/json"}}
+	done.Body = []byte(`{"id":1}`)
+	if err := store.Complete("k1", &done, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	existing, acquired, err = store.Acquire("k1", record, time.Minute)
+	if err != nil || acquired || !existing.Done || existing.Status != http.StatusCreated ||
+		existing.Header.Get("Content-Type") != "application/json" || string(existing.Body) != `{"id":1}` {
+		t.Fatalf("completed: %+v %v %v", existing, acquired, err)
+	}
+
+	if err := store.Release("k1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, acquired, err := store.Acquire("k1", record, -time.Second); err != nil || !acquired {
+		t.Fatalf("after release: %v %v", acquired, err)
+	}
+	// 已过期的记录在下一次获取时被清理
+	if _, acquired, err := store.Acquire("k1", record, time.Minute); err != nil || !acquired {
+		t.Fatalf("after expiry: %v %v", acquired, err)
+	}
+}
+
+func TestIdempotencyStoreReplicas(t *testing.T) {
+	// 副本中没有主库刚插入的行
+	replica := dbrtest.Open(t, idempotencySchema)
+	pool := dbr.NewReplicaPool(dbr.ReplicaRoundRobin).Add(replica.DB, 1)
+	sess := dbrtest.Open(t, idempotencySchema).WithReplicas(pool).NewSession(nil)
+	store := dbrstore.NewIdempotencyStore(sess)
+	record := &xrestful.IdempotencyRecord{Key: "k1", Fingerprint: "f1", CreatedAt: time.Now()}
+	if _, acquired, err := store.Acquire("k1", record, time.Minute); err != nil || !acquired {
+		t.Fatalf("first acquire: %v %v", acquired, err)
+	}
+	if existing, acquired, err := store.Acquire("k1", record, time.Minute); err != nil || acquired || existing == nil {
+		t.Fatalf("second acquire: %+v %v %v", existing, acquired, err)
+	}
+}
@@ -0,0 +1,40 @@
+// Package dbrtest provides sqlite backed dbr connections for tests
+package dbrtest
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/system18188/jupiter-plugin/store/dbr"
+)
+
+var seq int64
+
+// Open returns a connection to a new in-memory sqlite database and executes
+// the schema statements on it. The database is dropped when the test ends.
+func Open(tb testing.TB, schema ...string) *dbr.Connection {
+	tb.Helper()
+	// 共享缓存让连接池中的连接看到同一个库, 名字唯一保证测试之间相互隔离
+	dsn := fmt.Sprintf("file:dbrtest%d?mode=memory&cache=shared", atomic.AddInt64(&seq, 1))
+	conn, err := dbr.Open("sqlite3", dsn, nil)
+	if err != nil {
+		tb.Fatalf("dbrtest: open sqlite3: %v", err)
+	}
+	tb.Cleanup(func() {
+		conn.Close()
+	})
+	for _, stmt := range schema {
+		if _, err := conn.Exec(stmt); err != nil {
+			tb.Fatalf("dbrtest: exec %q: %v", stmt, err)
+		}
+	}
+	return conn
+}
+
+// Session returns a session on a new in-memory sqlite database, see Open
+func Session(tb testing.TB, schema ...string) *dbr.Session {
+	tb.Helper()
+	return Open(tb, schema...).NewSession(nil)
+}
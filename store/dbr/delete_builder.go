@@ -1,6 +1,7 @@
 package dbr
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 )
@@ -70,7 +71,12 @@ func (tx *Tx) DeleteBySql(query string, value ...interface{}) DeleteBuilder {
 
 // Exec executes the stmt
 func (b *deleteBuilder) Exec() (sql.Result, error) {
-	return exec(b.runner, b.EventReceiver, b, b.Dialect)
+	return b.ExecContext(b.context())
+}
+
+// ExecContext executes the stmt with ctx
+func (b *deleteBuilder) ExecContext(ctx context.Context) (sql.Result, error) {
+	return exec(ctx, b.runner, b.EventReceiver, b, b.Dialect)
 }
 
 // Where adds condition to the stmt
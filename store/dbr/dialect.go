@@ -15,4 +15,8 @@ type Dialect interface {
 	Proposed(column string) string
 	Limit(offset, limit int64) string
 	Prewhere() string
+	// Savepoint, RollbackSavepoint and ReleaseSavepoint return "" if savepoints are not supported
+	Savepoint(name string) string
+	RollbackSavepoint(name string) string
+	ReleaseSavepoint(name string) string
 }
@@ -90,3 +90,15 @@ func (d clickhouse) String() string {
 func (d clickhouse) Prewhere() string {
 	return "PREWHERE"
 }
+
+func (d clickhouse) Savepoint(_ string) string {
+	return ""
+}
+
+func (d clickhouse) RollbackSavepoint(_ string) string {
+	return ""
+}
+
+func (d clickhouse) ReleaseSavepoint(_ string) string {
+	return ""
+}
@@ -83,3 +83,15 @@ func (d mysql) Limit(offset, limit int64) string {
 func (d mysql) Prewhere() string {
 	return ""
 }
+
+func (d mysql) Savepoint(name string) string {
+	return "SAVEPOINT " + d.QuoteIdent(name)
+}
+
+func (d mysql) RollbackSavepoint(name string) string {
+	return "ROLLBACK TO SAVEPOINT " + d.QuoteIdent(name)
+}
+
+func (d mysql) ReleaseSavepoint(name string) string {
+	return "RELEASE SAVEPOINT " + d.QuoteIdent(name)
+}
@@ -54,3 +54,15 @@ func (d postgreSQL) Limit(offset, limit int64) string {
 func (d postgreSQL) Prewhere() string {
 	return ""
 }
+
+func (d postgreSQL) Savepoint(name string) string {
+	return "SAVEPOINT " + d.QuoteIdent(name)
+}
+
+func (d postgreSQL) RollbackSavepoint(name string) string {
+	return "ROLLBACK TO SAVEPOINT " + d.QuoteIdent(name)
+}
+
+func (d postgreSQL) ReleaseSavepoint(name string) string {
+	return "RELEASE SAVEPOINT " + d.QuoteIdent(name)
+}
@@ -57,3 +57,15 @@ func (d sqlite3) Limit(offset, limit int64) string {
 func (d sqlite3) Prewhere() string {
 	return ""
 }
+
+func (d sqlite3) Savepoint(name string) string {
+	return "SAVEPOINT " + d.QuoteIdent(name)
+}
+
+func (d sqlite3) RollbackSavepoint(name string) string {
+	return "ROLLBACK TO SAVEPOINT " + d.QuoteIdent(name)
+}
+
+func (d sqlite3) ReleaseSavepoint(name string) string {
+	return "RELEASE SAVEPOINT " + d.QuoteIdent(name)
+}
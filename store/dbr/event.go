@@ -1,5 +1,7 @@
 package dbr
 
+import "context"
+
 // EventReceiver gets events from dbr methods for logging purposes
 type EventReceiver interface {
 	Event(eventName string)
@@ -10,6 +12,14 @@ type EventReceiver interface {
 	TimingKv(eventName string, nanoseconds int64, kvs map[string]string)
 }
 
+// TracingEventReceiver is an optional interface of EventReceiver, it gets the
+// context of every query so the spans can be linked to the caller
+type TracingEventReceiver interface {
+	SpanStart(ctx context.Context, eventName, query string) context.Context
+	SpanError(ctx context.Context, err error)
+	SpanFinish(ctx context.Context)
+}
+
 type kvs map[string]string
 
 var nullReceiver = &NullEventReceiver{}
@@ -38,3 +48,55 @@ func (n *NullEventReceiver) Timing(eventName string, nanoseconds int64) {}
 
 // TimingKv receives the time an event took to happen along with optional key/value data
 func (n *NullEventReceiver) TimingKv(eventName string, nanoseconds int64, kvs map[string]string) {}
+
+// SpanStart forwards to the receiver of the session if it is a TracingEventReceiver
+func (sess *Session) SpanStart(ctx context.Context, eventName, query string) context.Context {
+	if tr, ok := sess.EventReceiver.(TracingEventReceiver); ok {
+		return tr.SpanStart(ctx, eventName, query)
+	}
+	return ctx
+}
+
+// SpanError ...
+func (sess *Session) SpanError(ctx context.Context, err error) {
+	if tr, ok := sess.EventReceiver.(TracingEventReceiver); ok {
+		tr.SpanError(ctx, err)
+	}
+}
+
+// SpanFinish ...
+func (sess *Session) SpanFinish(ctx context.Context) {
+	if tr, ok := sess.EventReceiver.(TracingEventReceiver); ok {
+		tr.SpanFinish(ctx)
+	}
+}
+
+// SpanStart forwards to the receiver of the transaction if it is a TracingEventReceiver
+func (tx *Tx) SpanStart(ctx context.Context, eventName, query string) context.Context {
+	if tr, ok := tx.EventReceiver.(TracingEventReceiver); ok {
+		return tr.SpanStart(ctx, eventName, query)
+	}
+	return ctx
+}
+
+// SpanError ...
+func (tx *Tx) SpanError(ctx context.Context, err error) {
+	if tr, ok := tx.EventReceiver.(TracingEventReceiver); ok {
+		tr.SpanError(ctx, err)
+	}
+}
+
+// SpanFinish ...
+func (tx *Tx) SpanFinish(ctx context.Context) {
+	if tr, ok := tx.EventReceiver.(TracingEventReceiver); ok {
+		tr.SpanFinish(ctx)
+	}
+}
+
+func (sess *Session) detailSQL() bool {
+	return wantDetail(sess.EventReceiver)
+}
+
+func (tx *Tx) detailSQL() bool {
+	return wantDetail(tx.EventReceiver)
+}
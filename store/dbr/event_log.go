@@ -0,0 +1,222 @@
+package dbr
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/douyu/jupiter/pkg/xlog"
+)
+
+// redacted replaces the values of the sensitive columns
+const redacted = "'***'"
+
+// detailer is a receiver that wants the fully interpolated SQL in the detail kv
+type detailer interface {
+	detailSQL() bool
+}
+
+// wantDetail reports whether log or one of its receivers wants the detail kv
+func wantDetail(log EventReceiver) bool {
+	d, ok := log.(detailer)
+	return ok && d.detailSQL()
+}
+
+// detailOf returns the statement with its values for the receivers that want
+// it, query already has them unless bind
+func detailOf(log EventReceiver, builder Builder, d Dialect, query string, bind bool) string {
+	if !wantDetail(log) {
+		return ""
+	}
+	if !bind {
+		return query
+	}
+	i := interpolator{
+		Buffer:       NewBuffer(),
+		Dialect:      d,
+		IgnoreBinary: true,
+	}
+	if err := i.interpolate(placeholder, []interface{}{builder}); err != nil {
+		return query
+	}
+	return i.String()
+}
+
+// withDetail adds the detail kv if it is not empty
+func (k kvs) withDetail(detail string) kvs {
+	if detail != "" {
+		k["detail"] = detail
+	}
+	return k
+}
+
+// logEventReceiver logs the errors and the slow statements to the logger of a Config
+type logEventReceiver struct {
+	NullEventReceiver
+	logger *xlog.Logger
+	// 慢查询阈值, 为0时不记录
+	slowThreshold time.Duration
+	detail        bool
+	sensitive     *sensitiveColumns
+}
+
+func newLogReceiver(config *Config) *logEventReceiver {
+	return &logEventReceiver{
+		logger:        config.logger,
+		slowThreshold: config.SlowThreshold,
+		detail:        config.DetailSQL,
+		sensitive:     newSensitiveColumns(config.SensitiveColumns),
+	}
+}
+
+func (n *logEventReceiver) detailSQL() bool {
+	return n.detail
+}
+
+// sql returns the SQL to log, the redacted detail with DetailSQL, otherwise the
+// statement with its values replaced by ?
+func (n *logEventReceiver) sql(kvs map[string]string) string {
+	if n.detail && kvs["detail"] != "" {
+		return n.sensitive.redact(kvs["detail"])
+	}
+	return fingerprint(kvs["sql"])
+}
+
+// EventErr ...
+func (n *logEventReceiver) EventErr(eventName string, err error) error {
+	n.logger.Error("dbr error", xlog.FieldMod("dbr"), xlog.FieldName(eventName), xlog.FieldErr(err))
+	return err
+}
+
+// EventErrKv ...
+func (n *logEventReceiver) EventErrKv(eventName string, err error, kvs map[string]string) error {
+	if errors.Is(err, ErrNotFound) {
+		return err
+	}
+	n.logger.Error("dbr error", xlog.FieldMod("dbr"), xlog.FieldName(eventName), xlog.FieldErr(err),
+		xlog.String("sql", n.sql(kvs)))
+	return err
+}
+
+// TimingKv logs the statements slower than the threshold at Warn, the others at Debug
+func (n *logEventReceiver) TimingKv(eventName string, nanoseconds int64, kvs map[string]string) {
+	cost := time.Duration(nanoseconds)
+	if n.slowThreshold > 0 && cost > n.slowThreshold {
+		n.logger.Warn("dbr slow", xlog.FieldMod("dbr"), xlog.FieldName(eventName), xlog.FieldCost(cost),
+			xlog.String("sql", n.sql(kvs)))
+		return
+	}
+	if n.logger.IsDebugMode() {
+		n.logger.Debug("dbr timing", xlog.FieldMod("dbr"), xlog.FieldName(eventName), xlog.FieldCost(cost),
+			xlog.String("sql", n.sql(kvs)))
+	}
+}
+
+// literalRegexp matches the values interpolated by the dialects
+var literalRegexp = regexp.MustCompile(`(?i)'(?:[^']|'')*'|\bX'[0-9a-f]*'|E'(?:[^'\\]|\\.|'')*'|\b-?\d+(?:\.\d+)?\b`)
+
+// fingerprint replaces the values of an interpolated statement with ?
+func fingerprint(query string) string {
+	return literalRegexp.ReplaceAllString(query, "?")
+}
+
+// sensitiveColumns redacts the values of columns in interpolated statements
+type sensitiveColumns struct {
+	columns map[string]bool
+	// col = value, col IN (...), SET col = value
+	compare *regexp.Regexp
+}
+
+var insertRegexp = regexp.MustCompile(`(?is)^(\s*(?:INSERT|REPLACE)\s+INTO\s+\S+\s*)\(([^)]*)\)(\s*VALUES\s*)(.*)$`)
+
+func newSensitiveColumns(columns []string) *sensitiveColumns {
+	if len(columns) == 0 {
+		return nil
+	}
+	s := &sensitiveColumns{columns: make(map[string]bool, len(columns))}
+	quoted := make([]string, 0, len(columns))
+	for _, c := range columns {
+		s.columns[strings.ToLower(c)] = true
+		quoted = append(quoted, regexp.QuoteMeta(c))
+	}
+	s.compare = regexp.MustCompile(`(?i)([` + "`" + `"]?\b(?:` + strings.Join(quoted, "|") + `)\b[` + "`" + `"]?\s*(?:=|!=|<>|>=|<=|>|<|\bNOT\s+LIKE\b|\bLIKE\b|\bNOT\s+IN\b|\bIN\b)\s*)` +
+		`('(?:[^']|'')*'|X'[0-9a-fA-F]*'|E'(?:[^'\\]|\\.|'')*'|-?\d+(?:\.\d+)?|\([^)]*\))`)
+	return s
+}
+
+func (s *sensitiveColumns) redact(query string) string {
+	if s == nil {
+		return query
+	}
+	if m := insertRegexp.FindStringSubmatch(query); m != nil {
+		positions := make(map[int]bool)
+		for i, c := range strings.Split(m[2], ",") {
+			if s.columns[strings.ToLower(strings.Trim(strings.TrimSpace(c), "`\""))] {
+				positions[i] = true
+			}
+		}
+		if len(positions) > 0 {
+			// VALUES 之后的 ON CONFLICT / ON DUPLICATE KEY 部分由 compare 处理
+			query = m[1] + "(" + m[2] + ")" + m[3] + redactTuples(m[4], positions)
+		}
+	}
+	return s.compare.ReplaceAllString(query, "${1}"+redacted)
+}
+
+// redactTuples replaces the values at positions of the tuples (a,b),(c,d) at the start of values
+func redactTuples(values string, positions map[int]bool) string {
+	var b strings.Builder
+	depth, index, quoted := 0, 0, false
+	skip := false
+	for i := 0; i < len(values); i++ {
+		c := values[i]
+		switch {
+		case quoted:
+			if c == '\'' {
+				if i+1 < len(values) && values[i+1] == '\'' {
+					if !skip {
+						b.WriteString("''")
+					}
+					i++
+					continue
+				}
+				quoted = false
+			}
+		case c == '\'':
+			quoted = true
+		case c == '(':
+			depth++
+			if depth == 1 {
+				index = 0
+				b.WriteByte(c)
+				skip = positions[0]
+				if skip {
+					b.WriteString(redacted)
+				}
+				continue
+			}
+		case c == ')':
+			depth--
+			if depth == 0 {
+				skip = false
+			}
+		case c == ',' && depth == 1:
+			index++
+			b.WriteByte(c)
+			skip = positions[index]
+			if skip {
+				b.WriteString(redacted)
+			}
+			continue
+		case depth == 0 && c != ',' && c != ' ' && c != '\n' && c != '\t':
+			// 元组结束, 其余部分原样保留
+			b.WriteString(values[i:])
+			return b.String()
+		}
+		if !skip {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
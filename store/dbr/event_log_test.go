@@ -0,0 +1,80 @@
+package dbr_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/douyu/jupiter/pkg/xlog"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/system18188/jupiter-plugin/store/dbr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLogReceiver(t *testing.T) {
+	cases := []struct {
+		name    string
+		prepare bool
+		detail  bool
+		want    []string
+	}{
+		{"fingerprint", false, false, []string{
+			`INSERT INTO "log_users" ("id","name","password") VALUES (?,?,?)`,
+			`SELECT name FROM log_users WHERE ("password" = ?)`,
+		}},
+		{"detail", false, true, []string{
+			`INSERT INTO "log_users" ("id","name","password") VALUES (1,'bob','***')`,
+			`SELECT name FROM log_users WHERE ("password" = '***')`,
+		}},
+		{"prepared detail", true, true, []string{
+			`INSERT INTO "log_users" ("id","name","password") VALUES (1,'bob','***')`,
+			`SELECT name FROM log_users WHERE ("password" = '***')`,
+		}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			core, logs := observer.New(zap.DebugLevel)
+			config := dbr.DefaultConfig().WithLogger(xlog.Config{Core: core, EncoderConfig: xlog.DefaultZapConfig()}.Build())
+			config.Drive = "sqlite3"
+			config.DSN = "file:log_" + strings.ReplaceAll(c.name, " ", "_") + "?mode=memory&cache=shared"
+			config.DisableMetric = true
+			config.DisableTrace = true
+			config.Prepare = c.prepare
+			config.DetailSQL = c.detail
+			config.SlowThreshold = time.Nanosecond
+			config.SensitiveColumns = []string{"password"}
+			conn := config.Build()
+			defer conn.Close()
+			if _, err := conn.Exec("CREATE TABLE log_users (id INTEGER PRIMARY KEY, name TEXT, password TEXT)"); err != nil {
+				t.Fatal(err)
+			}
+
+			sess := conn.NewSession(nil)
+			if _, err := sess.InsertInto("log_users").Pair("id", 1).Pair("name", "bob").Pair("password", "secret").Exec(); err != nil {
+				t.Fatal(err)
+			}
+			var name string
+			if err := sess.Select("name").From("log_users").Where(dbr.Eq("password", "secret")).LoadOne(&name); err != nil {
+				t.Fatal(err)
+			}
+
+			slow := logs.FilterMessage("dbr slow").All()
+			if len(slow) != len(c.want) {
+				t.Fatalf("%d slow logs", len(slow))
+			}
+			for i, entry := range slow {
+				if entry.Level != zap.WarnLevel {
+					t.Errorf("level %s", entry.Level)
+				}
+				fields := entry.ContextMap()
+				if fields["cost"] == nil {
+					t.Errorf("no cost in %v", fields)
+				}
+				if got := fields["sql"]; got != c.want[i] {
+					t.Errorf("got %v, want %s", got, c.want[i])
+				}
+			}
+		})
+	}
+}
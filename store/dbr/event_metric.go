@@ -0,0 +1,62 @@
+package dbr
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/douyu/jupiter/pkg/metric"
+)
+
+var (
+	handleHistogram = metric.HistogramVecOpts{
+		Namespace: metric.DefaultNamespace,
+		Name:      "dbr_handle_seconds",
+		Labels:    []string{"event", "table", "outcome"},
+	}.Build()
+
+	handleErrCounter = metric.CounterVecOpts{
+		Namespace: metric.DefaultNamespace,
+		Name:      "dbr_handle_errors_total",
+		Labels:    []string{"event", "table"},
+	}.Build()
+)
+
+// MetricReceiver records the latency of the statements by event, table and outcome
+// and counts the errors by event and table
+var MetricReceiver = &metricEventReceiver{}
+
+type metricEventReceiver struct {
+	NullEventReceiver
+}
+
+// EventErr ...
+func (n *metricEventReceiver) EventErr(eventName string, err error) error {
+	handleErrCounter.Inc(eventName, "")
+	return err
+}
+
+// EventErrKv ...
+func (n *metricEventReceiver) EventErrKv(eventName string, err error, kvs map[string]string) error {
+	handleErrCounter.Inc(eventName, tableOf(kvs["sql"]))
+	return err
+}
+
+// TimingKv ...
+func (n *metricEventReceiver) TimingKv(eventName string, nanoseconds int64, kvs map[string]string) {
+	out := kvs["outcome"]
+	if out == "" {
+		out = "ok"
+	}
+	handleHistogram.Observe(float64(nanoseconds)/1e9, eventName, tableOf(kvs["sql"]), out)
+}
+
+var tableRegexp = regexp.MustCompile("(?i)\\b(?:FROM|INTO|UPDATE|JOIN)\\s+([`\"\\w.]+)")
+
+// tableOf returns the first table of the statement, subqueries are skipped
+func tableOf(query string) string {
+	m := tableRegexp.FindStringSubmatch(query)
+	if m == nil {
+		return ""
+	}
+	return strings.NewReplacer("`", "", `"`, "").Replace(m[1])
+}
@@ -0,0 +1,105 @@
+package dbr
+
+import "context"
+
+// MultiEventReceiver sends the events to all its receivers, so logging,
+// metrics and tracing can be enabled at once
+//
+//	dbr.MultiEventReceiver{dbr.JupiterReceiver, dbr.MetricReceiver, dbr.TraceReceiver}
+type MultiEventReceiver []EventReceiver
+
+// Event ...
+func (m MultiEventReceiver) Event(eventName string) {
+	for _, r := range m {
+		r.Event(eventName)
+	}
+}
+
+// EventKv ...
+func (m MultiEventReceiver) EventKv(eventName string, kvs map[string]string) {
+	for _, r := range m {
+		r.EventKv(eventName, kvs)
+	}
+}
+
+// EventErr returns err unchanged
+func (m MultiEventReceiver) EventErr(eventName string, err error) error {
+	for _, r := range m {
+		r.EventErr(eventName, err)
+	}
+	return err
+}
+
+// EventErrKv returns err unchanged
+func (m MultiEventReceiver) EventErrKv(eventName string, err error, kvs map[string]string) error {
+	for _, r := range m {
+		r.EventErrKv(eventName, err, kvs)
+	}
+	return err
+}
+
+// Timing ...
+func (m MultiEventReceiver) Timing(eventName string, nanoseconds int64) {
+	for _, r := range m {
+		r.Timing(eventName, nanoseconds)
+	}
+}
+
+// TimingKv ...
+func (m MultiEventReceiver) TimingKv(eventName string, nanoseconds int64, kvs map[string]string) {
+	for _, r := range m {
+		r.TimingKv(eventName, nanoseconds, kvs)
+	}
+}
+
+// multiSpanKey keeps the contexts returned by the receivers of a MultiEventReceiver
+type multiSpanKey struct{}
+
+// SpanStart forwards to the receivers that are TracingEventReceiver, each of
+// them gets its own context back in SpanError and SpanFinish
+func (m MultiEventReceiver) SpanStart(ctx context.Context, eventName, query string) context.Context {
+	ctxs := make([]context.Context, len(m))
+	for i, r := range m {
+		if tr, ok := r.(TracingEventReceiver); ok {
+			ctx = tr.SpanStart(ctx, eventName, query)
+			ctxs[i] = ctx
+		}
+	}
+	return context.WithValue(ctx, multiSpanKey{}, ctxs)
+}
+
+// spanContext returns the context SpanStart returned for the i-th receiver
+func (m MultiEventReceiver) spanContext(ctx context.Context, i int) context.Context {
+	if ctxs, ok := ctx.Value(multiSpanKey{}).([]context.Context); ok && len(ctxs) == len(m) && ctxs[i] != nil {
+		return ctxs[i]
+	}
+	return ctx
+}
+
+// SpanError ...
+func (m MultiEventReceiver) SpanError(ctx context.Context, err error) {
+	for i, r := range m {
+		if tr, ok := r.(TracingEventReceiver); ok {
+			tr.SpanError(m.spanContext(ctx, i), err)
+		}
+	}
+}
+
+// SpanFinish ...
+func (m MultiEventReceiver) SpanFinish(ctx context.Context) {
+	for i, r := range m {
+		if tr, ok := r.(TracingEventReceiver); ok {
+			tr.SpanFinish(m.spanContext(ctx, i))
+		}
+	}
+}
+
+// detailSQL is true if one of the receivers wants the detail kv
+func (m MultiEventReceiver) detailSQL() bool {
+	for _, r := range m {
+		if wantDetail(r) {
+			return true
+		}
+	}
+	return false
+}
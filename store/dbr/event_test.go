@@ -0,0 +1,143 @@
+package dbr_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/system18188/jupiter-plugin/store/dbr"
+	"github.com/system18188/jupiter-plugin/store/dbr/dbrtest"
+)
+
+func TestReceivers(t *testing.T) {
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	conn := dbrtest.Open(t, "CREATE TABLE receiver_items (id INTEGER PRIMARY KEY)")
+	sess := conn.NewSession(dbr.MultiEventReceiver{dbr.JupiterReceiver, dbr.MetricReceiver, dbr.TraceReceiver})
+
+	parent := tracer.StartSpan("request")
+	ctx := opentracing.ContextWithSpan(context.Background(), parent)
+	if _, err := sess.InsertInto("receiver_items").Pair("id", 1).ExecContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sess.InsertInto("receiver_items").Pair("id", 1).ExecContext(ctx); err == nil {
+		t.Fatal("want duplicate key error")
+	}
+	parent.Finish()
+
+	spans := tracer.FinishedSpans()
+	if len(spans) != 3 {
+		t.Fatalf("%d spans", len(spans))
+	}
+	parentID := parent.Context().(mocktracer.MockSpanContext).SpanID
+	for i, span := range spans[:2] {
+		if span.OperationName != "dbr.exec" || span.ParentID != parentID {
+			t.Errorf("span %d: %s parent %d", i, span.OperationName, span.ParentID)
+		}
+		if span.Tag("db.statement") == nil {
+			t.Errorf("span %d: no db.statement", i)
+		}
+	}
+	if spans[0].Tag("error") != nil || spans[1].Tag("error") != true {
+		t.Errorf("error tags %v %v", spans[0].Tag("error"), spans[1].Tag("error"))
+	}
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := map[string]bool{}
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["table"] != "receiver_items" {
+				continue
+			}
+			switch family.GetName() {
+			case "jupiter_dbr_handle_seconds":
+				found[labels["event"]+":"+labels["outcome"]] = m.GetHistogram().GetSampleCount() == 1
+			case "jupiter_dbr_handle_errors_total":
+				found[labels["event"]] = m.GetCounter().GetValue() == 1
+			}
+		}
+	}
+	for _, key := range []string{"dbr.exec:ok", "dbr.exec:error", "dbr.exec.exec"} {
+		if !found[key] {
+			t.Errorf("metric %s not found in %v", key, found)
+		}
+	}
+}
+
+func TestTraceReceiverPlaceholders(t *testing.T) {
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	conn := dbrtest.Open(t, "CREATE TABLE trace_users (id INTEGER PRIMARY KEY, password TEXT)")
+	sess := conn.NewSession(dbr.TraceReceiver)
+	parent := tracer.StartSpan("request")
+	ctx := opentracing.ContextWithSpan(context.Background(), parent)
+	if _, err := sess.InsertInto("trace_users").Pair("id", 7).Pair("password", "hunter2").ExecContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var id int
+	if err := sess.Select("id").From("trace_users").Where(dbr.Eq("password", "hunter2")).LoadOneContext(ctx, &id); err != nil {
+		t.Fatal(err)
+	}
+	parent.Finish()
+
+	want := []string{
+		`INSERT INTO "trace_users" ("id","password") VALUES (?,?)`,
+		`SELECT id FROM trace_users WHERE ("password" = ?)`,
+	}
+	spans := tracer.FinishedSpans()
+	for i, statement := range want {
+		if got := spans[i].Tag("db.statement"); got != statement {
+			t.Errorf("span %d: db.statement %v", i, got)
+		}
+		for k, v := range spans[i].Tags() {
+			if s, ok := v.(string); ok && strings.Contains(s, "hunter2") {
+				t.Errorf("span %d: tag %s has the value: %s", i, k, s)
+			}
+		}
+	}
+}
+
+func TestMultiTraceReceivers(t *testing.T) {
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	conn := dbrtest.Open(t, "CREATE TABLE multi_trace_items (id INTEGER PRIMARY KEY)")
+	sess := conn.NewSession(dbr.MultiEventReceiver{dbr.TraceReceiver, dbr.TraceReceiver})
+	parent := tracer.StartSpan("request")
+	ctx := opentracing.ContextWithSpan(context.Background(), parent)
+	if _, err := sess.SelectBySql("SELECT nope FROM multi_trace_items").LoadContext(ctx, new([]int)); err == nil {
+		t.Fatal("want error")
+	}
+	parent.Finish()
+
+	// 两个语句span和父span各结束一次
+	spans := tracer.FinishedSpans()
+	if len(spans) != 3 {
+		t.Fatalf("%d finished spans", len(spans))
+	}
+	seen := make(map[int]bool)
+	for _, span := range spans {
+		if seen[span.SpanContext.SpanID] {
+			t.Fatalf("span %s finished twice", span.OperationName)
+		}
+		seen[span.SpanContext.SpanID] = true
+	}
+	if spans[0].Tag("error") != true || spans[1].Tag("error") != true {
+		t.Errorf("error tags %v %v", spans[0].Tags(), spans[1].Tags())
+	}
+}
@@ -0,0 +1,64 @@
+package dbr
+
+import (
+	"context"
+
+	"github.com/douyu/jupiter/pkg/trace"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+// TraceReceiver starts a span per statement as a child of the span of the
+// context, the SQL with placeholders instead of the values is set as the
+// db.statement tag
+var TraceReceiver = &traceEventReceiver{}
+
+type traceEventReceiver struct {
+	NullEventReceiver
+}
+
+// SpanStart ...
+func (n *traceEventReceiver) SpanStart(ctx context.Context, eventName, query string) context.Context {
+	_, ctx = trace.StartSpanFromContext(
+		ctx,
+		eventName,
+		trace.TagComponent("dbr"),
+		trace.TagSpanKind("client"),
+		trace.CustomTag("db.statement", query),
+	)
+	return ctx
+}
+
+// SpanError ...
+func (n *traceEventReceiver) SpanError(ctx context.Context, err error) {
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(log.Error(err))
+	}
+}
+
+// SpanFinish ...
+func (n *traceEventReceiver) SpanFinish(ctx context.Context) {
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		span.Finish()
+	}
+}
+
+// placeholderSQL returns the statement with placeholders instead of its values,
+// query already has them if bind
+func placeholderSQL(builder Builder, d Dialect, query string, bind bool) string {
+	if bind {
+		return query
+	}
+	i := interpolator{
+		Buffer:       NewBuffer(),
+		Dialect:      d,
+		IgnoreBinary: true,
+		Bind:         true,
+	}
+	if err := i.interpolate(placeholder, []interface{}{builder}); err != nil {
+		return fingerprint(query)
+	}
+	return i.String()
+}
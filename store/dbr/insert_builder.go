@@ -1,6 +1,7 @@
 package dbr
 
 import (
+	"context"
 	"database/sql"
 	"reflect"
 )
@@ -11,6 +12,7 @@ type InsertBuilder interface {
 	EventReceiver
 	Executer
 	LoadStruct(value interface{}) error
+	LoadStructContext(ctx context.Context, value interface{}) error
 	Columns(column ...string) InsertBuilder
 	Values(value ...interface{}) InsertBuilder
 	ScanStruct(value interface{}, column ...string) InsertBuilder
@@ -91,7 +93,12 @@ func (b *insertBuilder) Pair(column string, value interface{}) InsertBuilder {
 
 // Exec executes the stmt
 func (b *insertBuilder) Exec() (sql.Result, error) {
-	result, err := exec(b.runner, b.EventReceiver, b, b.Dialect)
+	return b.ExecContext(b.context())
+}
+
+// ExecContext executes the stmt with ctx
+func (b *insertBuilder) ExecContext(ctx context.Context) (sql.Result, error) {
+	result, err := exec(ctx, b.runner, b.EventReceiver, b, b.Dialect)
 	if err != nil {
 		return nil, err
 	}
@@ -105,8 +112,17 @@ func (b *insertBuilder) Exec() (sql.Result, error) {
 	return result, nil
 }
 
+// LoadStruct loads the RETURNING columns into value
 func (b *insertBuilder) LoadStruct(value interface{}) error {
-	_, err := query(b.runner, b.EventReceiver, b, b.Dialect, value)
+	return b.LoadStructContext(b.context(), value)
+}
+
+// LoadStructContext loads the RETURNING columns into value with ctx
+func (b *insertBuilder) LoadStructContext(ctx context.Context, value interface{}) error {
+	_, err := query(ctx, b.runner, b.EventReceiver, b, b.Dialect, value)
+	if w, ok := b.runner.(writeMarker); ok && err == nil {
+		w.markWrite()
+	}
 	return err
 }
 
@@ -14,7 +14,10 @@ type interpolator struct {
 	Buffer
 	Dialect
 	IgnoreBinary bool
-	N            int
+	// Bind writes values as dialect placeholders and keeps them as args,
+	// slices and maps are expanded to one placeholder per element
+	Bind bool
+	N    int
 }
 
 // InterpolateForDialect replaces placeholder in query with corresponding value in dialect
@@ -91,6 +94,12 @@ func (i *interpolator) encodePlaceholder(value interface{}) error {
 		return nil
 	}
 
+	if i.Bind && bindable(value) {
+		i.WriteString(i.Placeholder(i.N))
+		i.N++
+		return i.WriteValue(value)
+	}
+
 	if valuer, ok := value.(driver.Valuer); ok {
 		// get driver.Valuer's data
 		var err error
@@ -180,6 +189,24 @@ func (i *interpolator) encodePlaceholder(value interface{}) error {
 	return ErrNotSupported
 }
 
+// bindable reports whether value is passed to the driver as one arg
+func bindable(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	if _, ok := value.(driver.Valuer); ok {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Slice:
+		return v.Type().Elem().Kind() == reflect.Uint8
+	case reflect.Map:
+		return false
+	}
+	return true
+}
+
 type mapKeys []reflect.Value
 
 func (k mapKeys) Len() int {
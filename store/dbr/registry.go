@@ -0,0 +1,228 @@
+package dbr
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/douyu/jupiter/pkg/conf"
+	"github.com/douyu/jupiter/pkg/defers"
+	"github.com/douyu/jupiter/pkg/metric"
+	"github.com/douyu/jupiter/pkg/server/governor"
+	"github.com/douyu/jupiter/pkg/xlog"
+)
+
+// configPrefix is the config key of the connections, jupiter.dbr.<name>
+const configPrefix = "jupiter.dbr"
+
+var poolGauge = metric.GaugeVecOpts{
+	Namespace: metric.DefaultNamespace,
+	Name:      "dbr_pool",
+	Labels:    []string{"name", "db", "status"},
+}.Build()
+
+// Registry builds the connections of the jupiter.dbr.<name> keys on first use and caches them
+type Registry struct {
+	// 为nil时使用全局配置
+	config *conf.Configuration
+	mu     sync.Mutex
+	conns  map[string]*Connection
+}
+
+// NewRegistry returns a registry reading c, the global config when c is nil
+func NewRegistry(c *conf.Configuration) *Registry {
+	return &Registry{config: c, conns: make(map[string]*Connection)}
+}
+
+// DefaultRegistry is the registry of Invoker
+var DefaultRegistry = NewRegistry(nil)
+
+// stageAfterStop is jupiter.StageAfterStop, the jupiter package is not imported
+// to keep dbr usable without the application
+const stageAfterStop uint32 = 1
+
+// HookRegisterer is implemented by *jupiter.Application
+type HookRegisterer interface {
+	RegisterHooks(k uint32, fns ...func() error) error
+}
+
+// RegisterHooks closes the connections of r after app stops
+//
+//	app := jupiter.DefaultApp()
+//	dbr.DefaultRegistry.RegisterHooks(app)
+func (r *Registry) RegisterHooks(app HookRegisterer) error {
+	return app.RegisterHooks(stageAfterStop, r.Close)
+}
+
+func init() {
+	// 也随 defers.Clean 关闭, 与jupiter其他组件一致
+	defers.Register(DefaultRegistry.Close)
+	governor.HandleFunc("/debug/dbr/stats", func(w http.ResponseWriter, r *http.Request) {
+		encoder := json.NewEncoder(w)
+		if r.URL.Query().Get("pretty") == "true" {
+			encoder.SetIndent("", "    ")
+		}
+		_ = encoder.Encode(DefaultRegistry.Stats())
+	})
+	go monitor()
+}
+
+// Invoker returns the connection of jupiter.dbr.<name> of DefaultRegistry
+func Invoker(name string) *Connection {
+	return DefaultRegistry.Invoker(name)
+}
+
+// Invoker returns the connection of name, building it on first use
+func (r *Registry) Invoker(name string) *Connection {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if conn, ok := r.conns[name]; ok {
+		return conn
+	}
+	config := DefaultConfig()
+	key := configPrefix + "." + name
+	if err := r.unmarshal(key, config); err != nil {
+		xlog.Panic("unmarshal key", xlog.FieldMod("dbr"), xlog.FieldErr(err), xlog.FieldKey(key))
+	}
+	conn := config.Build()
+	r.conns[name] = conn
+	return conn
+}
+
+func (r *Registry) unmarshal(key string, config *Config) error {
+	if r.config != nil {
+		return r.config.UnmarshalKey(key, config, conf.TagName("toml"))
+	}
+	return conf.UnmarshalKey(key, config, conf.TagName("toml"))
+}
+
+// Names returns the names configured under jupiter.dbr
+func (r *Registry) Names() []string {
+	var m map[string]interface{}
+	if r.config != nil {
+		m = r.config.GetStringMap(configPrefix)
+	} else {
+		m = conf.GetStringMap(configPrefix)
+	}
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// InvokeAll builds the connections of all the configured names
+func (r *Registry) InvokeAll() map[string]*Connection {
+	conns := make(map[string]*Connection)
+	for _, name := range r.Names() {
+		conns[name] = r.Invoker(name)
+	}
+	return conns
+}
+
+// Range calls fn for the built connections until it returns false
+func (r *Registry) Range(fn func(name string, conn *Connection) bool) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.conns))
+	conns := make(map[string]*Connection, len(r.conns))
+	for name, conn := range r.conns {
+		names = append(names, name)
+		conns[name] = conn
+	}
+	r.mu.Unlock()
+	sort.Strings(names)
+	for _, name := range names {
+		if !fn(name, conns[name]) {
+			return
+		}
+	}
+}
+
+// Stats pool statistics of a connection
+type Stats struct {
+	sql.DBStats
+	// 副本的连接池统计, 键为序号
+	Replicas map[string]sql.DBStats `json:"replicas,omitempty"`
+	// 缓存的预处理语句数量
+	Statements int `json:"statements"`
+}
+
+// Stats returns the pool statistics of conn and its replicas
+func (conn *Connection) Stats() Stats {
+	stats := Stats{DBStats: conn.DB.Stats()}
+	if conn.stmts != nil {
+		stats.Statements = conn.stmts.Len()
+	}
+	if conn.replicas != nil {
+		stats.Replicas = make(map[string]sql.DBStats)
+		for i, replica := range conn.replicas.Replicas() {
+			stats.Replicas[replicaName(i)] = replica.Stats()
+		}
+	}
+	return stats
+}
+
+func replicaName(i int) string {
+	return "replica" + strconv.Itoa(i)
+}
+
+// Stats returns the pool statistics of the built connections
+func (r *Registry) Stats() map[string]Stats {
+	stats := make(map[string]Stats)
+	r.Range(func(name string, conn *Connection) bool {
+		stats[name] = conn.Stats()
+		return true
+	})
+	return stats
+}
+
+// Close closes and forgets the built connections
+func (r *Registry) Close() error {
+	r.mu.Lock()
+	conns := r.conns
+	r.conns = make(map[string]*Connection)
+	r.mu.Unlock()
+
+	var err error
+	for name, conn := range conns {
+		if e := conn.Close(); e != nil {
+			xlog.Error("close dbr", xlog.FieldMod("dbr"), xlog.FieldName(name), xlog.FieldErr(e))
+			if err == nil {
+				err = e
+			}
+		}
+	}
+	return err
+}
+
+// monitor publishes the pool statistics of DefaultRegistry
+func monitor() {
+	for {
+		time.Sleep(10 * time.Second)
+		DefaultRegistry.Range(func(name string, conn *Connection) bool {
+			observePool(name, "primary", conn.DB.Stats())
+			if conn.replicas != nil {
+				for i, replica := range conn.replicas.Replicas() {
+					observePool(name, replicaName(i), replica.Stats())
+				}
+			}
+			return true
+		})
+	}
+}
+
+func observePool(name, db string, stats sql.DBStats) {
+	poolGauge.Set(float64(stats.OpenConnections), name, db, "conns")
+	poolGauge.Set(float64(stats.InUse), name, db, "inuse")
+	poolGauge.Set(float64(stats.Idle), name, db, "idle")
+	poolGauge.Set(float64(stats.MaxOpenConnections), name, db, "max_open_conns")
+	poolGauge.Set(float64(stats.WaitCount), name, db, "wait")
+	poolGauge.Set(stats.WaitDuration.Seconds(), name, db, "wait_seconds")
+	poolGauge.Set(float64(stats.MaxIdleClosed), name, db, "max_idle_closed")
+	poolGauge.Set(float64(stats.MaxLifetimeClosed), name, db, "max_lifetime_closed")
+}
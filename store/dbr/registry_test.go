@@ -0,0 +1,89 @@
+package dbr_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/douyu/jupiter/pkg/conf"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/system18188/jupiter-plugin/store/dbr"
+)
+
+type hookApp struct {
+	stage uint32
+	fns   []func() error
+}
+
+func (app *hookApp) RegisterHooks(k uint32, fns ...func() error) error {
+	app.stage = k
+	app.fns = append(app.fns, fns...)
+	return nil
+}
+
+func TestRegistry(t *testing.T) {
+	c := conf.New()
+	err := c.LoadFromReader(strings.NewReader(`{"jupiter": {"dbr": {
+		"main": {"drive": "sqlite3", "dsn": "file:registry_main?mode=memory&cache=shared", "prepare": true},
+		"log": {"drive": "sqlite3", "dsn": "file:registry_log?mode=memory&cache=shared"}
+	}}}`), json.Unmarshal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := dbr.NewRegistry(c)
+	app := &hookApp{}
+	if err := r.RegisterHooks(app); err != nil || app.stage != 1 || len(app.fns) != 1 {
+		t.Fatalf("hooks %+v %v", app, err)
+	}
+
+	if names := r.Names(); strings.Join(names, ",") != "log,main" {
+		t.Fatalf("names %v", names)
+	}
+	main := r.Invoker("main")
+	if r.Invoker("main") != main {
+		t.Fatal("connection not cached")
+	}
+	if _, err := main.NewSession(nil).SelectBySql("SELECT 1").ReturnInt64(); err != nil {
+		t.Fatal(err)
+	}
+	if conns := r.InvokeAll(); len(conns) != 2 || conns["main"] != main {
+		t.Fatalf("conns %v", conns)
+	}
+	stats := r.Stats()
+	if len(stats) != 2 || stats["main"].Statements != 1 || stats["main"].OpenConnections == 0 {
+		t.Fatalf("stats %+v", stats)
+	}
+
+	if err := app.fns[0](); err != nil {
+		t.Fatal(err)
+	}
+	if err := main.Ping(); err == nil {
+		t.Fatal("connection not closed")
+	}
+	if len(r.Stats()) != 0 {
+		t.Fatal("registry not cleared")
+	}
+}
+
+func TestConnMaxLifetime(t *testing.T) {
+	c := conf.New()
+	err := c.LoadFromReader(strings.NewReader(`{"jupiter": {"dbr": {
+		"duration": {"drive": "sqlite3", "dsn": "file:lifetime_duration?mode=memory&cache=shared", "connMaxLifetime": "300s"},
+		"legacy": {"drive": "sqlite3", "dsn": "file:lifetime_legacy?mode=memory&cache=shared", "connMaxLifetime": 300}
+	}}}`), json.Unmarshal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"duration", "legacy"} {
+		config := dbr.DefaultConfig()
+		if err := c.UnmarshalKey("jupiter.dbr."+name, config, conf.TagName("toml")); err != nil {
+			t.Fatal(err)
+		}
+		conn := config.Build()
+		conn.Close()
+		if config.ConnMaxLifetime != 300*time.Second {
+			t.Errorf("%s: connMaxLifetime = %v", name, config.ConnMaxLifetime)
+		}
+	}
+}
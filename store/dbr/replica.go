@@ -0,0 +1,255 @@
+package dbr
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Replica balancing policies
+const (
+	ReplicaRandom     = "random"
+	ReplicaRoundRobin = "roundrobin"
+	ReplicaLeastConn  = "leastconn"
+)
+
+// Replica is a read-only database of a ReplicaPool
+type Replica struct {
+	*sql.DB
+	Weight int
+
+	// 连续的连接错误次数
+	failures int32
+	// 剔除到期时间, unix纳秒
+	ejectedUntil int64
+	// smooth weighted round-robin 的当前权重
+	current int
+	stmts   *StmtCache
+}
+
+// Ejected reports whether the replica is ejected after failures
+func (r *Replica) Ejected() bool {
+	return time.Now().UnixNano() < atomic.LoadInt64(&r.ejectedUntil)
+}
+
+// ReplicaPool routes the selects of sessions to replicas
+type ReplicaPool struct {
+	// random, roundrobin or leastconn
+	Policy string
+	// 连续连接错误达到该次数后剔除副本
+	MaxFailures int
+	// 剔除时长, 到期后重新尝试
+	EjectTime time.Duration
+	// 会话写入后该时间内的查询仍然读主库
+	ReadYourWrites time.Duration
+
+	mu       sync.Mutex
+	replicas []*Replica
+}
+
+// NewReplicaPool ...
+func NewReplicaPool(policy string) *ReplicaPool {
+	return &ReplicaPool{
+		Policy:      policy,
+		MaxFailures: 3,
+		EjectTime:   30 * time.Second,
+	}
+}
+
+// Add adds db as a replica with weight, weight <= 0 means 1
+func (p *ReplicaPool) Add(db *sql.DB, weight int) *ReplicaPool {
+	if weight <= 0 {
+		weight = 1
+	}
+	p.mu.Lock()
+	p.replicas = append(p.replicas, &Replica{DB: db, Weight: weight})
+	p.mu.Unlock()
+	return p
+}
+
+// Replicas returns the replicas of the pool
+func (p *ReplicaPool) Replicas() []*Replica {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*Replica(nil), p.replicas...)
+}
+
+// Close closes the replicas
+func (p *ReplicaPool) Close() error {
+	var err error
+	for _, r := range p.Replicas() {
+		if r.stmts != nil {
+			r.stmts.Close()
+		}
+		if e := r.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// pick returns a healthy replica, nil when all the replicas are ejected
+func (p *ReplicaPool) pick() *Replica {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	healthy := make([]*Replica, 0, len(p.replicas))
+	total := 0
+	for _, r := range p.replicas {
+		if !r.Ejected() {
+			healthy = append(healthy, r)
+			total += r.Weight
+		}
+	}
+	if len(healthy) == 0 {
+		return nil
+	}
+	switch p.Policy {
+	case ReplicaRoundRobin:
+		var best *Replica
+		for _, r := range healthy {
+			r.current += r.Weight
+			if best == nil || r.current > best.current {
+				best = r
+			}
+		}
+		best.current -= total
+		return best
+	case ReplicaLeastConn:
+		var best *Replica
+		var bestLoad float64
+		for _, r := range healthy {
+			load := float64(r.Stats().InUse) / float64(r.Weight)
+			if best == nil || load < bestLoad {
+				best, bestLoad = r, load
+			}
+		}
+		return best
+	default:
+		n := rand.Intn(total)
+		for _, r := range healthy {
+			if n -= r.Weight; n < 0 {
+				return r
+			}
+		}
+		return healthy[len(healthy)-1]
+	}
+}
+
+// observe counts the connection errors of r and ejects it after MaxFailures
+func (p *ReplicaPool) observe(r *Replica, err error) {
+	if !isConnError(err) {
+		atomic.StoreInt32(&r.failures, 0)
+		return
+	}
+	if int(atomic.AddInt32(&r.failures, 1)) >= p.MaxFailures {
+		atomic.StoreInt32(&r.failures, 0)
+		atomic.StoreInt64(&r.ejectedUntil, time.Now().Add(p.EjectTime).UnixNano())
+	}
+}
+
+// isConnError reports whether err means the database is unreachable,
+// errors of the query itself do not eject a replica
+func isConnError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &netErr)
+}
+
+// WithReplicas routes the selects of sessions to the replicas of pool, writes,
+// transactions and ForUpdate selects stay on the primary
+func (conn *Connection) WithReplicas(pool *ReplicaPool) *Connection {
+	conn.replicas = pool
+	if conn.stmts != nil {
+		for _, r := range pool.Replicas() {
+			r.stmts = NewStmtCache(r.DB, conn.stmts.size)
+		}
+	}
+	return conn
+}
+
+// ReplicaPool returns the replicas of conn, nil without read/write splitting
+func (conn *Connection) ReplicaPool() *ReplicaPool {
+	return conn.replicas
+}
+
+// UsePrimary forks the session to send all the queries to the primary
+func (sess *Session) UsePrimary() *Session {
+	s := sess.NewSession(nil)
+	s.primary = true
+	return s
+}
+
+// writeMarker is a runner that reads from the primary for a while after writes
+type writeMarker interface {
+	markWrite()
+}
+
+// markWrite starts the read-your-writes window of the session
+func (sess *Session) markWrite() {
+	if sess.replicas != nil && sess.replicas.ReadYourWrites > 0 {
+		atomic.StoreInt64(&sess.lastWrite, time.Now().UnixNano())
+	}
+}
+
+// reader returns the runner of the selects of the session
+func (sess *Session) reader() runner {
+	pool := sess.replicas
+	if pool == nil || sess.primary {
+		return sess
+	}
+	if pool.ReadYourWrites > 0 && time.Since(time.Unix(0, atomic.LoadInt64(&sess.lastWrite))) < pool.ReadYourWrites {
+		return sess
+	}
+	r := pool.pick()
+	if r == nil {
+		return sess
+	}
+	return &replicaRunner{sess: sess, pool: pool, replica: r}
+}
+
+// replicaRunner runs the selects of a session on a replica, falling back to
+// the primary on connection errors
+type replicaRunner struct {
+	sess    *Session
+	pool    *ReplicaPool
+	replica *Replica
+}
+
+func (r *replicaRunner) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return r.sess.ExecContext(ctx, query, args...)
+}
+
+func (r *replicaRunner) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	rows, err := r.replica.QueryContext(ctx, query, args...)
+	r.pool.observe(r.replica, err)
+	if isConnError(err) {
+		return r.sess.QueryContext(ctx, query, args...)
+	}
+	return rows, err
+}
+
+func (r *replicaRunner) context() context.Context {
+	return r.sess.ctx
+}
+
+func (r *replicaRunner) prepared() bool {
+	return r.sess.prepared() && r.replica.stmts != nil
+}
+
+func (r *replicaRunner) prepare(ctx context.Context, query string) (*sql.Stmt, func(), error) {
+	stmt, release, err := r.replica.stmts.Prepare(ctx, query)
+	r.pool.observe(r.replica, err)
+	if isConnError(err) {
+		return r.sess.prepare(ctx, query)
+	}
+	return stmt, release, err
+}
@@ -0,0 +1,64 @@
+package dbr_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/system18188/jupiter-plugin/store/dbr"
+	"github.com/system18188/jupiter-plugin/store/dbr/dbrtest"
+)
+
+func TestReplicas(t *testing.T) {
+	schema := "CREATE TABLE t (id INTEGER PRIMARY KEY, db TEXT)"
+	replica := dbrtest.Open(t, schema, "INSERT INTO t VALUES (1, 'replica')")
+	pool := dbr.NewReplicaPool(dbr.ReplicaRoundRobin).Add(replica.DB, 1)
+	pool.ReadYourWrites = 50 * time.Millisecond
+	conn := dbrtest.Open(t, schema).WithReplicas(pool)
+	sess := conn.NewSession(nil)
+
+	read := func(sess *dbr.Session) string {
+		t.Helper()
+		var db string
+		err := sess.Select("db").From("t").Where("id = ?", 1).LoadOne(&db)
+		if err == dbr.ErrNotFound {
+			return "none"
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return db
+	}
+
+	if got := read(sess); got != "replica" {
+		t.Fatalf("read %s, want replica", got)
+	}
+	if _, err := sess.InsertInto("t").Pair("id", 1).Pair("db", "primary").Exec(); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(sess); got != "primary" {
+		t.Fatalf("read after write %s, want primary", got)
+	}
+	if got := read(conn.NewSession(nil)); got != "replica" {
+		t.Fatalf("other session read %s, want replica", got)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if got := read(sess); got != "replica" {
+		t.Fatalf("read after window %s, want replica", got)
+	}
+	if got := read(sess.UsePrimary()); got != "primary" {
+		t.Fatalf("UsePrimary read %s", got)
+	}
+	var db string
+	if err := sess.Select("db").From("t").(dbr.PrimarySelector).UsePrimary().LoadOne(&db); err != nil || db != "primary" {
+		t.Fatalf("select UsePrimary read %s %v", db, err)
+	}
+
+	tx, err := sess.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.RollbackUnlessCommitted()
+	if err := tx.Select("db").From("t").LoadOne(&db); err != nil || db != "primary" {
+		t.Fatalf("tx read %s %v", db, err)
+	}
+}
@@ -1,6 +1,7 @@
 package dbr
 
 import (
+	"context"
 	"reflect"
 	"time"
 )
@@ -35,6 +36,19 @@ type SelectBuilder interface {
 	InTimezone(loc *time.Location) SelectBuilder
 }
 
+// Counter is implemented by the SelectBuilder of Session.Select and Tx.Select,
+// it is kept out of SelectBuilder so other implementations still satisfy it
+type Counter interface {
+	Count() (uint64, error)
+	CountContext(ctx context.Context) (uint64, error)
+}
+
+// PrimarySelector is implemented by the SelectBuilder of Session.Select and Tx.Select,
+// it is kept out of SelectBuilder like Counter
+type PrimarySelector interface {
+	UsePrimary() SelectBuilder
+}
+
 type selectBuilder struct {
 	runner
 	EventReceiver
@@ -42,6 +56,7 @@ type selectBuilder struct {
 	Dialect    Dialect
 	selectStmt *selectStmt
 	timezone   *time.Location
+	usePrimary bool
 }
 
 func prepareSelect(a []string) []interface{} {
@@ -120,18 +135,43 @@ func (b *selectBuilder) Build(d Dialect, buf Buffer) error {
 	return b.selectStmt.Build(d, buf)
 }
 
+// UsePrimary sends the select to the primary even if the session has replicas
+func (b *selectBuilder) UsePrimary() SelectBuilder {
+	b.usePrimary = true
+	return b
+}
+
+// reader returns the runner of the select, a replica when the session has one
+// and the select is not FOR UPDATE
+func (b *selectBuilder) reader() runner {
+	if sess, ok := b.runner.(*Session); ok && !b.usePrimary && !b.selectStmt.IsForUpdate {
+		return sess.reader()
+	}
+	return b.runner
+}
+
 // Load loads any value from query result
 func (b *selectBuilder) Load(value interface{}) (int, error) {
-	c, err := query(b.runner, b.EventReceiver, b, b.Dialect, value)
+	return b.LoadContext(b.context(), value)
+}
+
+// LoadContext loads any value from query result with ctx
+func (b *selectBuilder) LoadContext(ctx context.Context, value interface{}) (int, error) {
+	c, err := query(ctx, b.reader(), b.EventReceiver, b, b.Dialect, value)
 	if err == nil && b.timezone != nil {
 		b.changeTimezone(reflect.ValueOf(value))
 	}
 	return c, err
 }
 
-// LoadStruct loads struct from query result, returns ErrNotFound if there is no result
-func (b *selectBuilder) LoadStruct(value interface{}) error {
-	count, err := query(b.runner, b.EventReceiver, b, b.Dialect, value)
+// LoadOne loads one row into a struct or a value, returns ErrNotFound if there is no result
+func (b *selectBuilder) LoadOne(value interface{}) error {
+	return b.LoadOneContext(b.context(), value)
+}
+
+// LoadOneContext loads one row into a struct or a value with ctx, returns ErrNotFound if there is no result
+func (b *selectBuilder) LoadOneContext(ctx context.Context, value interface{}) error {
+	count, err := query(ctx, b.reader(), b.EventReceiver, b, b.Dialect, value)
 	if err != nil {
 		return err
 	}
@@ -144,37 +184,70 @@ func (b *selectBuilder) LoadStruct(value interface{}) error {
 	return nil
 }
 
+// LoadStruct loads struct from query result, returns ErrNotFound if there is no result
+func (b *selectBuilder) LoadStruct(value interface{}) error {
+	return b.LoadOneContext(b.context(), value)
+}
+
+// LoadStructContext loads struct from query result with ctx, returns ErrNotFound if there is no result
+func (b *selectBuilder) LoadStructContext(ctx context.Context, value interface{}) error {
+	return b.LoadOneContext(ctx, value)
+}
+
 // LoadStructs loads structures from query result
 func (b *selectBuilder) LoadStructs(value interface{}) (int, error) {
-	c, err := query(b.runner, b.EventReceiver, b, b.Dialect, value)
-	if err == nil && b.timezone != nil {
-		b.changeTimezone(reflect.ValueOf(value))
-	}
-	return c, err
+	return b.LoadContext(b.context(), value)
+}
+
+// LoadStructsContext loads structures from query result with ctx
+func (b *selectBuilder) LoadStructsContext(ctx context.Context, value interface{}) (int, error) {
+	return b.LoadContext(ctx, value)
 }
 
 // LoadValue loads any value from query result, returns ErrNotFound if there is no result
 func (b *selectBuilder) LoadValue(value interface{}) error {
-	count, err := query(b.runner, b.EventReceiver, b, b.Dialect, value)
-	if err != nil {
-		return err
-	}
-	if count == 0 {
-		return ErrNotFound
-	}
-	if b.timezone != nil {
-		b.changeTimezone(reflect.ValueOf(value))
-	}
-	return nil
+	return b.LoadOneContext(b.context(), value)
+}
+
+// LoadValueContext loads any value from query result with ctx, returns ErrNotFound if there is no result
+func (b *selectBuilder) LoadValueContext(ctx context.Context, value interface{}) error {
+	return b.LoadOneContext(ctx, value)
 }
 
 // LoadValues loads any values from query result
 func (b *selectBuilder) LoadValues(value interface{}) (int, error) {
-	c, err := query(b.runner, b.EventReceiver, b, b.Dialect, value)
-	if err == nil && b.timezone != nil {
-		b.changeTimezone(reflect.ValueOf(value))
+	return b.LoadContext(b.context(), value)
+}
+
+// LoadValuesContext loads any values from query result with ctx
+func (b *selectBuilder) LoadValuesContext(ctx context.Context, value interface{}) (int, error) {
+	return b.LoadContext(ctx, value)
+}
+
+// Count runs SELECT COUNT(*) with the tables and conditions of b, ORDER BY, LIMIT and
+// OFFSET are ignored. DISTINCT, GROUP BY and raw queries are counted as a subquery.
+func (b *selectBuilder) Count() (uint64, error) {
+	return b.CountContext(b.context())
+}
+
+// CountContext is Count with ctx
+func (b *selectBuilder) CountContext(ctx context.Context) (uint64, error) {
+	stmt := *b.selectStmt
+	stmt.Order = nil
+	stmt.LimitCount, stmt.OffsetCount = -1, -1
+	stmt.IsForUpdate = false
+
+	var counter SelectStmt
+	if stmt.raw.Query != "" || stmt.IsDistinct || len(stmt.Group) > 0 {
+		counter = createSelectStmt([]interface{}{"COUNT(*)"}).From(stmt.As("dbr_count"))
+	} else {
+		stmt.Column = []interface{}{"COUNT(*)"}
+		counter = &stmt
 	}
-	return c, err
+	var n uint64
+	// 以 BuildFunc 传入, 顶层的 SelectStmt 不加括号
+	_, err := query(ctx, b.reader(), b.EventReceiver, BuildFunc(counter.Build), b.Dialect, &n)
+	return n, err
 }
 
 // Join joins table on condition
@@ -249,7 +322,8 @@ func (b *selectBuilder) OrderDir(col string, isAsc bool) SelectBuilder {
 
 // Paginate adds LIMIT and OFFSET
 func (b *selectBuilder) Paginate(page, perPage uint64) SelectBuilder {
-	return b.Paginate(page, perPage)
+	b.selectStmt.Paginate(page, perPage)
+	return b
 }
 
 // OrderBy specifies column for ordering
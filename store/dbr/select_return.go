@@ -1,5 +1,7 @@
 package dbr
 
+import "context"
+
 //
 // These are a set of helpers that just call LoadValue and return the value.
 // They return (_, ErrNotFound) if nothing was found.
@@ -30,46 +32,83 @@ type typesLoader interface {
 	ReturnUint64s() ([]uint64, error)
 	ReturnString() (string, error)
 	ReturnStrings() ([]string, error)
+
+	ReturnInt64Context(ctx context.Context) (int64, error)
+	ReturnInt64sContext(ctx context.Context) ([]int64, error)
+	ReturnUint64Context(ctx context.Context) (uint64, error)
+	ReturnUint64sContext(ctx context.Context) ([]uint64, error)
+	ReturnStringContext(ctx context.Context) (string, error)
+	ReturnStringsContext(ctx context.Context) ([]string, error)
 }
 
 // ReturnInt64 executes the SelectStmt and returns the value as an int64
 func (b *selectBuilder) ReturnInt64() (int64, error) {
+	return b.ReturnInt64Context(b.context())
+}
+
+// ReturnInt64Context executes the SelectStmt with ctx and returns the value as an int64
+func (b *selectBuilder) ReturnInt64Context(ctx context.Context) (int64, error) {
 	var v int64
-	err := b.LoadValue(&v)
+	err := b.LoadValueContext(ctx, &v)
 	return v, err
 }
 
 // ReturnInt64s executes the SelectStmt and returns the value as a slice of int64s
 func (b *selectBuilder) ReturnInt64s() ([]int64, error) {
+	return b.ReturnInt64sContext(b.context())
+}
+
+// ReturnInt64sContext executes the SelectStmt with ctx and returns the value as a slice of int64s
+func (b *selectBuilder) ReturnInt64sContext(ctx context.Context) ([]int64, error) {
 	var v []int64
-	_, err := b.LoadValues(&v)
+	_, err := b.LoadValuesContext(ctx, &v)
 	return v, err
 }
 
 // ReturnUint64 executes the SelectStmt and returns the value as an uint64
 func (b *selectBuilder) ReturnUint64() (uint64, error) {
+	return b.ReturnUint64Context(b.context())
+}
+
+// ReturnUint64Context executes the SelectStmt with ctx and returns the value as an uint64
+func (b *selectBuilder) ReturnUint64Context(ctx context.Context) (uint64, error) {
 	var v uint64
-	err := b.LoadValue(&v)
+	err := b.LoadValueContext(ctx, &v)
 	return v, err
 }
 
 // ReturnUint64s executes the SelectStmt and returns the value as a slice of uint64s
 func (b *selectBuilder) ReturnUint64s() ([]uint64, error) {
+	return b.ReturnUint64sContext(b.context())
+}
+
+// ReturnUint64sContext executes the SelectStmt with ctx and returns the value as a slice of uint64s
+func (b *selectBuilder) ReturnUint64sContext(ctx context.Context) ([]uint64, error) {
 	var v []uint64
-	_, err := b.LoadValues(&v)
+	_, err := b.LoadValuesContext(ctx, &v)
 	return v, err
 }
 
 // ReturnString executes the SelectStmt and returns the value as a string
 func (b *selectBuilder) ReturnString() (string, error) {
+	return b.ReturnStringContext(b.context())
+}
+
+// ReturnStringContext executes the SelectStmt with ctx and returns the value as a string
+func (b *selectBuilder) ReturnStringContext(ctx context.Context) (string, error) {
 	var v string
-	err := b.LoadValue(&v)
+	err := b.LoadValueContext(ctx, &v)
 	return v, err
 }
 
 // ReturnStrings executes the SelectStmt and returns the value as a slice of strings
 func (b *selectBuilder) ReturnStrings() ([]string, error) {
+	return b.ReturnStringsContext(b.context())
+}
+
+// ReturnStringsContext executes the SelectStmt with ctx and returns the value as a slice of strings
+func (b *selectBuilder) ReturnStringsContext(ctx context.Context) ([]string, error) {
 	var v []string
-	_, err := b.LoadValues(&v)
+	_, err := b.LoadValuesContext(ctx, &v)
 	return v, err
 }
@@ -0,0 +1,220 @@
+package dbr
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// StmtCache is an LRU cache of prepared statements keyed by SQL text
+type StmtCache struct {
+	db    *sql.DB
+	size  int
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type cachedStmt struct {
+	query string
+	stmt  *sql.Stmt
+	// 正在使用该语句的调用数, 被淘汰的语句在最后一个调用释放后关闭
+	refs    int
+	evicted bool
+}
+
+// NewStmtCache returns a cache of at most size statements prepared on db
+func NewStmtCache(db *sql.DB, size int) *StmtCache {
+	if size <= 0 {
+		size = 256
+	}
+	return &StmtCache{
+		db:    db,
+		size:  size,
+		ll:    list.New(),
+		items: make(map[string]*list.Element, size),
+	}
+}
+
+// Prepare returns the cached statement of query, preparing it on a miss. The
+// statement stays open until release is called, even if it is evicted meanwhile
+func (c *StmtCache) Prepare(ctx context.Context, query string) (stmt *sql.Stmt, release func(), err error) {
+	if entry := c.get(query); entry != nil {
+		return entry.stmt, c.releaser(entry), nil
+	}
+	stmt, err = c.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c.mu.Lock()
+	if el, ok := c.items[query]; ok {
+		// 并发准备了同一条语句, 使用先放入缓存的
+		c.ll.MoveToFront(el)
+		entry := el.Value.(*cachedStmt)
+		entry.refs++
+		c.mu.Unlock()
+		stmt.Close()
+		return entry.stmt, c.releaser(entry), nil
+	}
+	entry := &cachedStmt{query: query, stmt: stmt, refs: 1}
+	c.items[query] = c.ll.PushFront(entry)
+	var closing []*sql.Stmt
+	for c.ll.Len() > c.size {
+		el := c.ll.Back()
+		c.ll.Remove(el)
+		evicted := el.Value.(*cachedStmt)
+		delete(c.items, evicted.query)
+		if s := evicted.evict(); s != nil {
+			closing = append(closing, s)
+		}
+	}
+	c.mu.Unlock()
+
+	// Close 会等待正在执行的查询结束, 不能持有锁
+	for _, s := range closing {
+		s.Close()
+	}
+	return stmt, c.releaser(entry), nil
+}
+
+func (c *StmtCache) get(query string) *cachedStmt {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[query]
+	if !ok {
+		return nil
+	}
+	c.ll.MoveToFront(el)
+	entry := el.Value.(*cachedStmt)
+	entry.refs++
+	return entry
+}
+
+// releaser returns the release func of a use of entry
+func (c *StmtCache) releaser(entry *cachedStmt) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.mu.Lock()
+			entry.refs--
+			closing := entry.evicted && entry.refs == 0
+			c.mu.Unlock()
+			if closing {
+				entry.stmt.Close()
+			}
+		})
+	}
+}
+
+// evict marks the entry evicted and returns the statement to close if it is not in use
+func (entry *cachedStmt) evict() *sql.Stmt {
+	entry.evicted = true
+	if entry.refs > 0 {
+		return nil
+	}
+	return entry.stmt
+}
+
+// Len returns the number of cached statements
+func (c *StmtCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+// Close closes all the cached statements, the statements in use are closed when released
+func (c *StmtCache) Close() error {
+	c.mu.Lock()
+	items := c.items
+	c.items = make(map[string]*list.Element, c.size)
+	c.ll.Init()
+	c.mu.Unlock()
+
+	var closing []*sql.Stmt
+	c.mu.Lock()
+	for _, el := range items {
+		if s := el.Value.(*cachedStmt).evict(); s != nil {
+			closing = append(closing, s)
+		}
+	}
+	c.mu.Unlock()
+
+	var err error
+	for _, s := range closing {
+		if e := s.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// preparer is a runner that sends values as args of cached prepared statements
+type preparer interface {
+	prepared() bool
+	// prepare returns the statement of query and the func to call once it is no longer used
+	prepare(ctx context.Context, query string) (*sql.Stmt, func(), error)
+}
+
+// WithStmtCache switches conn to server-side prepared statements, values are sent
+// as args instead of being interpolated and at most size statements are cached.
+// ClickHouse does not support it.
+func (conn *Connection) WithStmtCache(size int) *Connection {
+	conn.stmts = NewStmtCache(conn.DB, size)
+	if conn.replicas != nil {
+		for _, r := range conn.replicas.Replicas() {
+			r.stmts = NewStmtCache(r.DB, size)
+		}
+	}
+	return conn
+}
+
+// StmtCache returns the statement cache, nil when values are interpolated
+func (conn *Connection) StmtCache() *StmtCache {
+	return conn.stmts
+}
+
+// Close closes the cached statements, the replicas and the database
+func (conn *Connection) Close() error {
+	if conn.stmts != nil {
+		conn.stmts.Close()
+	}
+	if conn.replicas != nil {
+		conn.replicas.Close()
+	}
+	return conn.DB.Close()
+}
+
+// Interpolated forks the session to interpolate values into the SQL text even if
+// the connection uses prepared statements, useful to debug the queries
+func (sess *Session) Interpolated() *Session {
+	s := sess.NewSession(nil)
+	s.interpolate = true
+	return s
+}
+
+func (sess *Session) prepared() bool {
+	return sess.stmts != nil && !sess.interpolate
+}
+
+func (sess *Session) prepare(ctx context.Context, query string) (*sql.Stmt, func(), error) {
+	return sess.stmts.Prepare(ctx, query)
+}
+
+func (tx *Tx) prepared() bool {
+	return tx.stmts != nil
+}
+
+// prepare returns the cached statement bound to the transaction
+func (tx *Tx) prepare(ctx context.Context, query string) (*sql.Stmt, func(), error) {
+	stmt, release, err := tx.stmts.Prepare(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	txStmt := tx.StmtContext(ctx, stmt)
+	return txStmt, func() {
+		txStmt.Close()
+		release()
+	}, nil
+}
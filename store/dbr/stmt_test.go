@@ -0,0 +1,138 @@
+package dbr_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/system18188/jupiter-plugin/store/dbr"
+	"github.com/system18188/jupiter-plugin/store/dbr/dbrtest"
+)
+
+type sqlRecorder struct {
+	dbr.NullEventReceiver
+	mu  sync.Mutex
+	sql []string
+}
+
+func (r *sqlRecorder) TimingKv(eventName string, nanoseconds int64, kvs map[string]string) {
+	r.mu.Lock()
+	r.sql = append(r.sql, kvs["sql"])
+	r.mu.Unlock()
+}
+
+func (r *sqlRecorder) last() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sql[len(r.sql)-1]
+}
+
+func TestStmtCache(t *testing.T) {
+	conn := dbrtest.Open(t, "CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT)").WithStmtCache(2)
+	rec := &sqlRecorder{}
+	sess := conn.NewSession(rec)
+
+	for i := 1; i <= 3; i++ {
+		if _, err := sess.InsertInto("t").Pair("id", i).Pair("name", "n'"+string(rune('a'+i))).Exec(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := rec.last(); got != `INSERT INTO "t" ("id","name") VALUES (?,?)` {
+		t.Fatalf("sql %q", got)
+	}
+	if conn.StmtCache().Len() != 1 {
+		t.Fatalf("cached %d statements", conn.StmtCache().Len())
+	}
+
+	var names []string
+	if _, err := sess.Select("name").From("t").Where(dbr.Eq("id", []int{1, 3})).OrderBy("id").Load(&names); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(names, ",") != "n'b,n'd" {
+		t.Fatalf("names %v", names)
+	}
+	if got := rec.last(); got != `SELECT name FROM t WHERE ("id" IN (?,?)) ORDER BY id` {
+		t.Fatalf("sql %q", got)
+	}
+
+	tx, err := sess.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Update("t").Set("name", "x").Where("id = ?", 2).Exec(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	// 容量为2, 最早的INSERT被淘汰
+	if conn.StmtCache().Len() != 2 {
+		t.Fatalf("cached %d statements", conn.StmtCache().Len())
+	}
+
+	var name string
+	if err := sess.Interpolated().Select("name").From("t").Where("id = ?", 2).LoadValue(&name); err != nil {
+		t.Fatal(err)
+	}
+	if name != "x" || rec.last() != "SELECT name FROM t WHERE (id = 2)" {
+		t.Fatalf("name %q sql %q", name, rec.last())
+	}
+	if err := conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStmtCacheEvictInUse(t *testing.T) {
+	conn := dbrtest.Open(t, "CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT)", "INSERT INTO t VALUES (1, 'a')").WithStmtCache(1)
+	sess := conn.NewSession(nil)
+
+	stmts := dbr.NewStmtCache(conn.DB, 1)
+	a, releaseA, err := stmts.Prepare(context.Background(), "SELECT name FROM t WHERE id = ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, releaseB, err := stmts.Prepare(context.Background(), "SELECT id FROM t WHERE name = ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	releaseB()
+	// a 已被淘汰, 但释放前仍然可用
+	var name string
+	if err := a.QueryRow(1).Scan(&name); err != nil || name != "a" {
+		t.Fatalf("evicted statement in use: %q %v", name, err)
+	}
+	releaseA()
+	if err := a.QueryRow(1).Scan(&name); err == nil {
+		t.Fatal("want the released statement closed")
+	}
+
+	// 两条语句交替使用, 每次准备都会淘汰另一条正在被其它协程使用的语句
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				var err error
+				if (g+i)%2 == 0 {
+					var name string
+					err = sess.Select("name").From("t").Where("id = ?", 1).LoadOne(&name)
+				} else {
+					var id int
+					err = sess.Select("id").From("t").Where("name = ?", "a").LoadOne(&id)
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
@@ -3,6 +3,12 @@ package dbr
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // Tx is a transaction for the given Session
@@ -11,6 +17,18 @@ type Tx struct {
 	Dialect Dialect
 	*sql.Tx
 	ctx context.Context
+	// 事务内使用 tx.Stmt 绑定缓存的预处理语句
+	stmts *StmtCache
+	sess  *Session
+
+	// 嵌套事务的外层事务和保存点, 见 Savepoint
+	parent    *Tx
+	savepoint string
+	// 已创建的保存点数量, 仅最外层事务使用
+	savepoints int
+	done       bool
+	// 提交后执行的函数, 见 AfterCommit
+	afterCommit []func()
 }
 
 // Begin creates a transaction for the given session
@@ -20,32 +38,65 @@ func (sess *Session) Begin() (*Tx, error) {
 
 // BeginWithOptions creates a transaction for the given section with ability to set TxOpts
 func (sess *Session) BeginWithOpts(opts *sql.TxOptions) (*Tx, error) {
-	tx, err := sess.beginTx(opts)
+	return sess.BeginContext(sess.ctx, opts)
+}
+
+// BeginContext creates a transaction bound to ctx, ctx is used by the statements of the transaction
+func (sess *Session) BeginContext(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	tx, err := sess.beginTx(ctx, opts)
 	if err != nil {
 		return nil, sess.EventErr("dbr.begin.error", err)
 	}
 	sess.Event("dbr.begin")
 
-	return &Tx{
+	t := &Tx{
 		EventReceiver: sess,
 		Dialect:       sess.Dialect,
 		Tx:            tx,
-		ctx:           sess.ctx,
-	}, nil
+		ctx:           ctx,
+		sess:          sess,
+	}
+	t.ctx = context.WithValue(ctx, txKey{}, t)
+	if sess.prepared() {
+		t.stmts = sess.stmts
+	}
+	return t, nil
+}
+
+func (tx *Tx) context() context.Context {
+	return tx.ctx
 }
 
-// Commit finishes the transaction
+// Context returns the context of the transaction, a Session.Transaction with
+// this context joins the transaction with a savepoint
+func (tx *Tx) Context() context.Context {
+	return tx.ctx
+}
+
+// Commit finishes the transaction, for a savepoint it releases the savepoint
 func (tx *Tx) Commit() error {
+	if tx.parent != nil {
+		return tx.release()
+	}
 	err := tx.Tx.Commit()
 	if err != nil {
 		return tx.EventErr("dbr.commit.error", err)
 	}
+	tx.sess.markWrite()
 	tx.Event("dbr.commit")
+	hooks := tx.afterCommit
+	tx.afterCommit = nil
+	for _, hook := range hooks {
+		hook()
+	}
 	return nil
 }
 
-// Rollback cancels the transaction
+// Rollback cancels the transaction, for a savepoint it rolls back to the savepoint
 func (tx *Tx) Rollback() error {
+	if tx.parent != nil {
+		return tx.rollbackTo()
+	}
 	err := tx.Tx.Rollback()
 	if err != nil {
 		return tx.EventErr("dbr.rollback", err)
@@ -58,6 +109,12 @@ func (tx *Tx) Rollback() error {
 // Useful to defer tx.RollbackUnlessCommitted() -- so you don't have to handle N failure cases
 // Keep in mind the only way to detect an error on the rollback is via the event log.
 func (tx *Tx) RollbackUnlessCommitted() {
+	if tx.parent != nil {
+		if !tx.done {
+			tx.rollbackTo()
+		}
+		return
+	}
 	err := tx.Tx.Rollback()
 	if err == sql.ErrTxDone {
 		// ok
@@ -67,3 +124,174 @@ func (tx *Tx) RollbackUnlessCommitted() {
 		tx.Event("dbr.rollback")
 	}
 }
+
+// AfterCommit registers fn to run after the outermost transaction commits, the
+// functions of a savepoint that is rolled back are dropped
+func (tx *Tx) AfterCommit(fn func()) {
+	tx.afterCommit = append(tx.afterCommit, fn)
+}
+
+// Savepoint starts a nested transaction, Commit releases the savepoint and
+// Rollback rolls back to it
+func (tx *Tx) Savepoint() (*Tx, error) {
+	root := tx
+	for root.parent != nil {
+		root = root.parent
+	}
+	root.savepoints++
+	name := fmt.Sprintf("dbr_sp_%d", root.savepoints)
+	query := tx.Dialect.Savepoint(name)
+	if query == "" {
+		return nil, ErrSavepointNotSupported
+	}
+	if _, err := tx.Tx.ExecContext(tx.ctx, query); err != nil {
+		return nil, tx.EventErrKv("dbr.savepoint.error", err, kvs{"sql": query})
+	}
+	tx.EventKv("dbr.savepoint", kvs{"sql": query})
+
+	sp := *tx
+	sp.parent = tx
+	sp.savepoint = name
+	sp.done = false
+	sp.afterCommit = nil
+	sp.ctx = context.WithValue(tx.ctx, txKey{}, &sp)
+	return &sp, nil
+}
+
+func (tx *Tx) release() error {
+	query := tx.Dialect.ReleaseSavepoint(tx.savepoint)
+	if _, err := tx.Tx.ExecContext(tx.ctx, query); err != nil {
+		return tx.EventErrKv("dbr.release.error", err, kvs{"sql": query})
+	}
+	tx.done = true
+	tx.parent.afterCommit = append(tx.parent.afterCommit, tx.afterCommit...)
+	tx.afterCommit = nil
+	tx.EventKv("dbr.release", kvs{"sql": query})
+	return nil
+}
+
+func (tx *Tx) rollbackTo() error {
+	query := tx.Dialect.RollbackSavepoint(tx.savepoint)
+	tx.done = true
+	tx.afterCommit = nil
+	if _, err := tx.Tx.ExecContext(tx.ctx, query); err != nil {
+		return tx.EventErrKv("dbr.rollback_to.error", err, kvs{"sql": query})
+	}
+	tx.EventKv("dbr.rollback_to", kvs{"sql": query})
+	return nil
+}
+
+// Transaction runs fn in a savepoint of tx, see Session.Transaction
+func (tx *Tx) Transaction(fn func(tx *Tx) error) error {
+	sp, err := tx.Savepoint()
+	if err != nil {
+		return err
+	}
+	return run(sp, fn)
+}
+
+// run commits tx if fn returns nil, otherwise or if fn panics it rolls back
+func run(tx *Tx, fn func(tx *Tx) error) error {
+	defer func() {
+		if p := recover(); p != nil {
+			tx.RollbackUnlessCommitted()
+			panic(p)
+		}
+	}()
+	if err := fn(tx); err != nil {
+		tx.RollbackUnlessCommitted()
+		return err
+	}
+	return tx.Commit()
+}
+
+// TxOptions are the options of Session.Transaction
+type TxOptions struct {
+	sql.TxOptions
+	// 序列化失败和死锁时的最大重试次数, 默认3, 为负数时不重试
+	MaxRetries int
+	// 第一次重试前的等待时间, 之后每次加倍, 默认10ms
+	Backoff time.Duration
+	// 重试等待时间上限, 默认1s
+	MaxBackoff time.Duration
+}
+
+type txKey struct{}
+
+// Transaction runs fn in a transaction, it commits if fn returns nil and rolls
+// back if fn returns an error or panics. The whole transaction is run again
+// with backoff when it fails with a serialization failure or a deadlock, so fn
+// must not have side effects out of the database, register them with AfterCommit.
+//
+// If ctx is the context of a transaction of the same connection, see Tx.Context,
+// fn runs in a savepoint of that transaction and is not retried.
+//
+//	err := sess.Transaction(ctx, nil, func(tx *dbr.Tx) error {
+//		if _, err := tx.Update("account").Set("balance", ...).ExecContext(tx.Context()); err != nil {
+//			return err
+//		}
+//		tx.AfterCommit(func() { cache.Delete(id) })
+//		return nil
+//	})
+func (sess *Session) Transaction(ctx context.Context, opts *TxOptions, fn func(tx *Tx) error) error {
+	if parent, ok := ctx.Value(txKey{}).(*Tx); ok && parent.sess.Connection == sess.Connection {
+		return parent.Transaction(fn)
+	}
+	if opts == nil {
+		opts = &TxOptions{}
+	}
+	retries, backoff, maxBackoff := opts.MaxRetries, opts.Backoff, opts.MaxBackoff
+	if retries == 0 {
+		retries = 3
+	}
+	if backoff <= 0 {
+		backoff = 10 * time.Millisecond
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = time.Second
+	}
+
+	for attempt := 0; ; attempt++ {
+		tx, err := sess.BeginContext(ctx, &opts.TxOptions)
+		if err != nil {
+			return err
+		}
+		err = run(tx, fn)
+		if err == nil || attempt >= retries || !IsRetryable(err) {
+			return err
+		}
+
+		wait := backoff << uint(attempt)
+		if wait <= 0 || wait > maxBackoff {
+			wait = maxBackoff
+		}
+		wait = wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+		sess.EventKv("dbr.transaction.retry", kvs{
+			"attempt": strconv.Itoa(attempt + 1),
+			"err":     err.Error(),
+		})
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+	}
+}
+
+// IsRetryable reports whether err is a serialization failure or a deadlock
+// after which the transaction can be run again
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	// lib/pq 和 pgx 的错误提供 SQLState
+	var state interface{ SQLState() string }
+	if errors.As(err, &state) {
+		switch state.SQLState() {
+		case "40001", "40P01":
+			return true
+		}
+	}
+	// go-sql-driver/mysql: Error 1213: Deadlock found ... 或 Error 1213 (40001): ...
+	return strings.Contains(err.Error(), "Error 1213")
+}
@@ -0,0 +1,159 @@
+package dbr_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/system18188/jupiter-plugin/store/dbr"
+	"github.com/system18188/jupiter-plugin/store/dbr/dbrtest"
+)
+
+type sqlStateError string
+
+func (e sqlStateError) Error() string    { return "pq: " + string(e) }
+func (e sqlStateError) SQLState() string { return string(e) }
+
+func txIDs(t *testing.T, sess *dbr.Session) []int {
+	t.Helper()
+	var ids []int
+	if _, err := sess.Select("id").From("tx_items").OrderAsc("id").Load(&ids); err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+func TestTransaction(t *testing.T) {
+	sess := dbrtest.Session(t, "CREATE TABLE tx_items (id INTEGER PRIMARY KEY)")
+	ctx := context.Background()
+	insert := func(tx *dbr.Tx, id int) error {
+		_, err := tx.InsertInto("tx_items").Pair("id", id).Exec()
+		return err
+	}
+
+	hooks := 0
+	if err := sess.Transaction(ctx, nil, func(tx *dbr.Tx) error {
+		tx.AfterCommit(func() { hooks++ })
+		return insert(tx, 1)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	boom := errors.New("boom")
+	if err := sess.Transaction(ctx, nil, func(tx *dbr.Tx) error {
+		tx.AfterCommit(func() { hooks++ })
+		if err := insert(tx, 2); err != nil {
+			return err
+		}
+		return boom
+	}); err != boom {
+		t.Fatalf("got %v", err)
+	}
+
+	func() {
+		defer func() {
+			if p := recover(); p != "panic" {
+				t.Fatalf("recovered %v", p)
+			}
+		}()
+		sess.Transaction(ctx, nil, func(tx *dbr.Tx) error {
+			insert(tx, 3)
+			panic("panic")
+		})
+	}()
+
+	if ids := txIDs(t, sess); !reflect.DeepEqual(ids, []int{1}) || hooks != 1 {
+		t.Fatalf("ids %v hooks %d", ids, hooks)
+	}
+}
+
+func TestTransactionRetry(t *testing.T) {
+	sess := dbrtest.Session(t, "CREATE TABLE tx_items (id INTEGER PRIMARY KEY)")
+	opts := &dbr.TxOptions{Backoff: time.Millisecond}
+
+	attempts, hooks := 0, 0
+	if err := sess.Transaction(context.Background(), opts, func(tx *dbr.Tx) error {
+		attempts++
+		tx.AfterCommit(func() { hooks++ })
+		if _, err := tx.InsertInto("tx_items").Pair("id", 1).Exec(); err != nil {
+			return err
+		}
+		if attempts < 3 {
+			return fmt.Errorf("update: %w", sqlStateError("40001"))
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 3 || hooks != 1 {
+		t.Fatalf("attempts %d hooks %d", attempts, hooks)
+	}
+
+	attempts = 0
+	opts.MaxRetries = -1
+	err := sess.Transaction(context.Background(), opts, func(tx *dbr.Tx) error {
+		attempts++
+		return sqlStateError("40P01")
+	})
+	if !dbr.IsRetryable(err) || attempts != 1 {
+		t.Fatalf("attempts %d err %v", attempts, err)
+	}
+
+	if !dbr.IsRetryable(errors.New("Error 1213: Deadlock found when trying to get lock")) ||
+		dbr.IsRetryable(sqlStateError("23505")) || dbr.IsRetryable(nil) {
+		t.Fatal("IsRetryable")
+	}
+}
+
+func TestTransactionSavepoint(t *testing.T) {
+	sess := dbrtest.Session(t, "CREATE TABLE tx_items (id INTEGER PRIMARY KEY)")
+	insert := func(tx *dbr.Tx, id int) error {
+		_, err := tx.InsertInto("tx_items").Pair("id", id).Exec()
+		return err
+	}
+
+	var hooks []int
+	boom := errors.New("boom")
+	if err := sess.Transaction(context.Background(), nil, func(tx *dbr.Tx) error {
+		if err := insert(tx, 1); err != nil {
+			return err
+		}
+		// 通过事务的 context 嵌套
+		err := sess.Transaction(tx.Context(), nil, func(tx *dbr.Tx) error {
+			tx.AfterCommit(func() { hooks = append(hooks, 2) })
+			if err := insert(tx, 2); err != nil {
+				return err
+			}
+			return tx.Transaction(func(tx *dbr.Tx) error {
+				tx.AfterCommit(func() { hooks = append(hooks, 3) })
+				return insert(tx, 3)
+			})
+		})
+		if err != nil {
+			return err
+		}
+		err = tx.Transaction(func(tx *dbr.Tx) error {
+			tx.AfterCommit(func() { hooks = append(hooks, 4) })
+			if err := insert(tx, 4); err != nil {
+				return err
+			}
+			return boom
+		})
+		if err != boom {
+			return fmt.Errorf("got %v", err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if ids := txIDs(t, sess); !reflect.DeepEqual(ids, []int{1, 2, 3}) {
+		t.Fatalf("ids %v", ids)
+	}
+	if !reflect.DeepEqual(hooks, []int{2, 3}) {
+		t.Fatalf("hooks %v", hooks)
+	}
+}
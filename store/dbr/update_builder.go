@@ -1,6 +1,7 @@
 package dbr
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 )
@@ -73,7 +74,12 @@ func (tx *Tx) UpdateBySql(query string, value ...interface{}) UpdateBuilder {
 
 // Exec executes the stmt
 func (b *updateBuilder) Exec() (sql.Result, error) {
-	return exec(b.runner, b.EventReceiver, b, b.Dialect)
+	return b.ExecContext(b.context())
+}
+
+// ExecContext executes the stmt with ctx
+func (b *updateBuilder) ExecContext(ctx context.Context) (sql.Result, error) {
+	return exec(ctx, b.runner, b.EventReceiver, b, b.Dialect)
 }
 
 // Set adds "SET column=value"
@@ -1,6 +1,8 @@
 package scs
 
 import (
+	"fmt"
+
 	restful "github.com/emicklei/go-restful/v3"
 	"github.com/system18188/jupiter-plugin/store/scs/memstore"
 	"log"
@@ -184,6 +186,22 @@ func (s *SessionManager) LoadAndSave() restful.FilterFunction {
 	}
 }
 
+// PrincipalFunc returns the string session value of key as the principal of a request,
+// e.g. for xrestful.AuditConfig.WithPrincipal(session.PrincipalFunc("userID"))
+func (s *SessionManager) PrincipalFunc(key string) func(req *restful.Request) string {
+	return func(req *restful.Request) string {
+		ctx := req.Request.Context()
+		// LoadAndSave 未执行时没有session
+		if _, ok := ctx.Value(s.contextKey).(*sessionData); !ok {
+			return ""
+		}
+		if v := s.Get(ctx, key); v != nil {
+			return fmt.Sprint(v)
+		}
+		return ""
+	}
+}
+
 func addHeaderIfMissing(w http.ResponseWriter, key, value string) {
 	for _, h := range w.Header()[key] {
 		if h == value {